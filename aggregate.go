@@ -0,0 +1,337 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultAggregateInterval is the default interval after which an
+	// Aggregator sends the digest of buffered messages.
+	DefaultAggregateInterval = 5 * time.Minute
+	// DefaultAggregateMaxMessages is the default number of buffered
+	// messages after which an Aggregator sends the digest immediately.
+	DefaultAggregateMaxMessages = 50
+)
+
+// ErrAggregated is returned by an Aggregator when a message was not sent
+// immediately, but buffered to be sent as part of a digest.
+var ErrAggregated = errors.New("pushover: message buffered for digest")
+
+// Aggregator sends messages with a Sender, but coalesces messages of
+// low priority into a digest, i.e. a single combined message, which is
+// sent on a timer or when too many messages are buffered.
+//
+// Messages with a priority above the maximum priority of the Aggregator
+// (Low by default), e.g. High or Emergency, are sent immediately. If the
+// Aggregator sends with Client.Messages, messages of DefaultPriority get
+// the default priority of the client; otherwise, they are treated like
+// messages of Normal priority.
+//
+// Messages are buffered by priority, recipient and group key; see
+// WithAggregateGroup. If the digest exceeds the maximum length of a
+// message, it is split into multiple parts, unless an overflow URL
+// is configured with WithAggregateOverflowURL.
+//
+// The digest is formatted with HTML if any of its messages is formatted
+// with HTML or Markdown. Attachments of messages combined into a digest
+// are dropped.
+type Aggregator struct {
+	s             Sender
+	interval      time.Duration
+	maxMessages   int
+	maxPriority   Priority
+	defaultPrio   func() Priority // resolves DefaultPriority, if set
+	group         func(Message) string
+	overflowURL   string
+	overflowTitle string
+	onError       func(error)
+
+	mu      sync.Mutex
+	buckets map[aggregateKey]*aggregateBucket
+}
+
+// aggregateKey identifies the messages that can be combined into
+// a single digest.
+type aggregateKey struct {
+	group    string
+	priority Priority
+	user     string
+	token    string
+	app      string
+	devices  string
+}
+
+// aggregateBucket holds the buffered messages of a digest.
+type aggregateBucket struct {
+	messages []Message
+	timer    *time.Timer
+}
+
+// AggregateOption for configuring Aggregator settings.
+type AggregateOption func(*Aggregator)
+
+// WithAggregateInterval sets the interval after which the digest of
+// buffered messages is sent (default: DefaultAggregateInterval).
+func WithAggregateInterval(interval time.Duration) AggregateOption {
+	return func(a *Aggregator) {
+		a.interval = interval
+	}
+}
+
+// WithAggregateMaxMessages sets the number of buffered messages after
+// which the digest is sent immediately (default: DefaultAggregateMaxMessages).
+func WithAggregateMaxMessages(n int) AggregateOption {
+	return func(a *Aggregator) {
+		a.maxMessages = n
+	}
+}
+
+// WithAggregatePriority sets the maximum priority of messages that are
+// buffered (default: Low). Messages of higher priority are sent
+// immediately. The maximum priority is capped at Normal.
+func WithAggregatePriority(max Priority) AggregateOption {
+	return func(a *Aggregator) {
+		if max > Normal {
+			max = Normal
+		}
+		a.maxPriority = max
+	}
+}
+
+// WithAggregateGroup specifies a function that returns the group key
+// of a message. Messages are only combined with other messages of the
+// same group key. By default, all messages share the same group key.
+func WithAggregateGroup(fn func(Message) string) AggregateOption {
+	return func(a *Aggregator) {
+		a.group = fn
+	}
+}
+
+// WithAggregateOverflowURL specifies a URL, e.g. of a dashboard, that is
+// added to a digest that is too long to be sent as a single message. The
+// digest is then truncated instead of being split into multiple parts.
+func WithAggregateOverflowURL(url, title string) AggregateOption {
+	return func(a *Aggregator) {
+		a.overflowURL = url
+		a.overflowTitle = title
+	}
+}
+
+// WithAggregateErrorHandler specifies a function that is called when
+// sending a digest in the background fails.
+func WithAggregateErrorHandler(fn func(error)) AggregateOption {
+	return func(a *Aggregator) {
+		a.onError = fn
+	}
+}
+
+// NewAggregator creates an Aggregator that sends messages with s,
+// e.g. Client.Messages.
+func NewAggregator(s Sender, options ...AggregateOption) *Aggregator {
+	a := &Aggregator{
+		s:           s,
+		interval:    DefaultAggregateInterval,
+		maxMessages: DefaultAggregateMaxMessages,
+		maxPriority: Low,
+		group:       func(Message) string { return "" },
+		buckets:     make(map[aggregateKey]*aggregateBucket),
+	}
+	if r, ok := s.(interface{ defaultPriority() Priority }); ok {
+		a.defaultPrio = r.defaultPriority
+	}
+	for _, o := range options {
+		o(a)
+	}
+	return a
+}
+
+// Send sends m immediately if its priority is above the maximum priority
+// of the Aggregator. Otherwise, m is buffered and ErrAggregated is
+// returned, unless the number of buffered messages reaches the maximum
+// and the digest is sent immediately.
+func (a *Aggregator) Send(ctx context.Context, m Message) (*SendResponse, error) {
+	if m.Priority == DefaultPriority && a.defaultPrio != nil {
+		m.Priority = a.defaultPrio()
+	}
+	priority := m.Priority
+	if priority == DefaultPriority {
+		// The default priority of the Sender is unknown
+		priority = Normal
+	}
+	if priority > a.maxPriority {
+		return a.s.Send(ctx, m)
+	}
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+	key := aggregateKey{
+		group:    a.group(m),
+		priority: m.Priority,
+		user:     m.User,
+		token:    m.Token,
+		app:      m.App,
+		devices:  strings.Join(m.Devices, ","),
+	}
+
+	a.mu.Lock()
+	b, found := a.buckets[key]
+	if !found {
+		b = &aggregateBucket{}
+		b.timer = time.AfterFunc(a.interval, func() { a.expire(key, b) })
+		a.buckets[key] = b
+	}
+	b.messages = append(b.messages, m)
+	full := a.maxMessages > 0 && len(b.messages) >= a.maxMessages
+	if full {
+		b.timer.Stop()
+		delete(a.buckets, key)
+	}
+	a.mu.Unlock()
+
+	if !full {
+		return nil, ErrAggregated
+	}
+	responses, err := a.sendDigest(ctx, key, b.messages)
+	if err != nil {
+		return nil, err
+	}
+	return responses[len(responses)-1], nil
+}
+
+// Flush sends the digests of all buffered messages.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	buckets := a.buckets
+	a.buckets = make(map[aggregateKey]*aggregateBucket)
+	a.mu.Unlock()
+
+	var firstErr error
+	for key, b := range buckets {
+		b.timer.Stop()
+		if _, err := a.sendDigest(ctx, key, b.messages); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close sends the digests of all buffered messages, like Flush.
+func (a *Aggregator) Close() error {
+	return a.Flush(context.Background())
+}
+
+// expire sends the digest of b when its interval has passed.
+func (a *Aggregator) expire(key aggregateKey, b *aggregateBucket) {
+	a.mu.Lock()
+	if a.buckets[key] != b {
+		// Already sent
+		a.mu.Unlock()
+		return
+	}
+	delete(a.buckets, key)
+	a.mu.Unlock()
+
+	if _, err := a.sendDigest(context.Background(), key, b.messages); err != nil && a.onError != nil {
+		a.onError(err)
+	}
+}
+
+// sendDigest sends the messages combined into a digest.
+func (a *Aggregator) sendDigest(ctx context.Context, key aggregateKey, messages []Message) ([]*SendResponse, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	if len(messages) == 1 {
+		resp, err := a.s.Send(ctx, messages[0])
+		if err != nil {
+			return nil, err
+		}
+		return []*SendResponse{resp}, nil
+	}
+
+	var responses []*SendResponse
+	for _, m := range a.digest(key, messages) {
+		resp, err := a.s.Send(ctx, m)
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// digest combines messages into a digest. It returns multiple messages
+// if the digest needs to be split into parts.
+func (a *Aggregator) digest(key aggregateKey, messages []Message) []Message {
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Timestamp.Before(messages[j].Timestamp)
+	})
+
+	first := messages[0]
+	d := Message{
+		Title:    fmt.Sprintf("%d messages", len(messages)),
+		Priority: key.priority,
+		Devices:  first.Devices,
+		User:     first.User,
+		Token:    first.Token,
+		App:      first.App,
+		Sound:    first.Sound,
+	}
+	if key.group != "" {
+		d.Title = fmt.Sprintf("%s: %s", key.group, d.Title)
+	}
+
+	for _, m := range messages {
+		d.HTML = d.HTML || m.HTML || m.Markdown
+	}
+	lines := make([]string, len(messages))
+	for i, m := range messages {
+		text, title := m.Message, m.Title
+		if d.HTML {
+			switch {
+			case m.Markdown:
+				text = MarkdownToHTML(text, 0)
+			case !m.HTML:
+				text = html.EscapeString(text)
+			}
+			title = html.EscapeString(title)
+		}
+		line := strings.Join(strings.Fields(text), " ")
+		if title != "" {
+			line = title + ": " + line
+		}
+		lines[i] = m.Timestamp.Format("15:04") + " " + line
+	}
+	d.Message = strings.Join(lines, "\n")
+	if utf8.RuneCountInString(d.Message) <= 1024 {
+		return []Message{d}
+	}
+
+	if a.overflowURL == "" {
+		return SplitMessage(d, 1024)
+	}
+
+	// Add as many lines as possible, and refer to the overflow URL
+	d.URL = a.overflowURL
+	d.URLTitle = a.overflowTitle
+	var sb strings.Builder
+	for i, line := range lines {
+		more := fmt.Sprintf("… and %d more", len(lines)-i)
+		if utf8.RuneCountInString(sb.String())+utf8.RuneCountInString(line)+1+utf8.RuneCountInString(more) > 1024 {
+			sb.WriteString(more)
+			break
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	d.Message = sb.String()
+	return []Message{d}
+}
@@ -0,0 +1,183 @@
+package pushover
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestAggregator(t *testing.T) {
+	s := &recordingSender{}
+	a := NewAggregator(s, WithAggregateInterval(time.Hour))
+	ctx := context.Background()
+	ts := time.Date(2020, 9, 1, 12, 30, 0, 0, time.UTC)
+
+	for i, text := range []string{"Backup done", "Cache warmed", "Report ready"} {
+		m := Message{Title: "Job", Message: text, Priority: Low, Timestamp: ts.Add(time.Duration(i) * time.Minute)}
+		if _, err := a.Send(ctx, m); err != ErrAggregated {
+			t.Fatalf("want %v, have %v", ErrAggregated, err)
+		}
+	}
+	// High priority messages are passed through
+	if _, err := a.Send(ctx, Message{Message: "Server down", Priority: High}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(s.sent()); want != have {
+		t.Fatalf("want %d message sent, have %d", want, have)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sent := s.sent()
+	if want, have := 2, len(sent); want != have {
+		t.Fatalf("want %d messages sent, have %d", want, have)
+	}
+	digest := sent[1]
+	if want, have := "3 messages", digest.Title; want != have {
+		t.Fatalf("want Title=%q, have %q", want, have)
+	}
+	if want, have := "12:30 Job: Backup done\n12:31 Job: Cache warmed\n12:32 Job: Report ready", digest.Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+	if want, have := Low, digest.Priority; want != have {
+		t.Fatalf("want Priority=%v, have %v", want, have)
+	}
+}
+
+func TestAggregatorGroupsAndTimer(t *testing.T) {
+	s := &recordingSender{}
+	a := NewAggregator(s,
+		WithAggregateInterval(50*time.Millisecond),
+		WithAggregateGroup(func(m Message) string { return m.Title }),
+	)
+	defer a.Close()
+	ctx := context.Background()
+
+	a.Send(ctx, Message{Title: "cron", Message: "a", Priority: Lowest})
+	a.Send(ctx, Message{Title: "cron", Message: "b", Priority: Lowest})
+	a.Send(ctx, Message{Title: "ci", Message: "c", Priority: Lowest})
+	a.Send(ctx, Message{Title: "ci", Message: "d", Priority: Low})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.sent()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("digests not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	titles := make(map[string]bool)
+	for _, m := range s.sent() {
+		titles[m.Title] = true
+	}
+	for _, want := range []string{"cron: 2 messages", "ci", "ci"} {
+		if !titles[want] {
+			t.Fatalf("want message with title %q, have %v", want, titles)
+		}
+	}
+}
+
+func TestAggregatorMaxMessages(t *testing.T) {
+	s := &recordingSender{}
+	a := NewAggregator(s, WithAggregateInterval(time.Hour), WithAggregateMaxMessages(3), WithAggregatePriority(Normal))
+	defer a.Close()
+	ctx := context.Background()
+
+	a.Send(ctx, Message{Message: "1"})
+	a.Send(ctx, Message{Message: "2"})
+	resp, err := a.Send(ctx, Message{Message: "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("want response of digest, have nil")
+	}
+	if want, have := 1, len(s.sent()); want != have {
+		t.Fatalf("want %d message sent, have %d", want, have)
+	}
+}
+
+func TestAggregatorOverflow(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	s := &recordingSender{}
+	a := NewAggregator(s, WithAggregateInterval(time.Hour), WithAggregateMaxMessages(0))
+	for i := 0; i < 20; i++ {
+		a.Send(context.Background(), Message{Message: long, Priority: Low})
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sent := s.sent()
+	if len(sent) < 2 {
+		t.Fatalf("want digest split into parts, have %d messages", len(sent))
+	}
+	for _, m := range sent {
+		if n := utf8.RuneCountInString(m.Message); n > 1024 {
+			t.Fatalf("want at most 1024 characters, have %d", n)
+		}
+	}
+
+	s = &recordingSender{}
+	a = NewAggregator(s, WithAggregateInterval(time.Hour), WithAggregateOverflowURL("https://example.com/alerts", "All alerts"))
+	for i := 0; i < 20; i++ {
+		a.Send(context.Background(), Message{Message: long, Priority: Low})
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sent = s.sent()
+	if want, have := 1, len(sent); want != have {
+		t.Fatalf("want %d message, have %d", want, have)
+	}
+	if n := utf8.RuneCountInString(sent[0].Message); n > 1024 {
+		t.Fatalf("want at most 1024 characters, have %d", n)
+	}
+	if want, have := "https://example.com/alerts", sent[0].URL; want != have {
+		t.Fatalf("want URL=%q, have %q", want, have)
+	}
+	if !strings.HasSuffix(sent[0].Message, "… and 11 more") {
+		t.Fatalf("want reference to overflow, have %q", sent[0].Message)
+	}
+}
+
+func TestAggregatorHTML(t *testing.T) {
+	s := &recordingSender{}
+	a := NewAggregator(s, WithAggregateInterval(time.Hour))
+	ctx := context.Background()
+	ts := time.Date(2020, 9, 1, 12, 30, 0, 0, time.UTC)
+
+	for i, m := range []Message{
+		{Title: "Build <main>", Message: "1 < 2"},
+		{Message: "<b>Deployed</b>", HTML: true},
+		{Message: "**Cache** warmed", Markdown: true, Attachment: "LICENSE"},
+	} {
+		m.Priority = Low
+		m.Timestamp = ts.Add(time.Duration(i) * time.Minute)
+		if _, err := a.Send(ctx, m); err != ErrAggregated {
+			t.Fatalf("want %v, have %v", ErrAggregated, err)
+		}
+	}
+	if err := a.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sent := s.sent()
+	if want, have := 1, len(sent); want != have {
+		t.Fatalf("want %d message sent, have %d", want, have)
+	}
+	digest := sent[0]
+	if !digest.HTML {
+		t.Fatal("want HTML=true, have false")
+	}
+	if digest.Attachment != "" {
+		t.Fatalf("want no attachment, have %q", digest.Attachment)
+	}
+	want := "12:30 Build &lt;main&gt;: 1 &lt; 2\n" +
+		"12:31 <b>Deployed</b>\n" +
+		"12:32 <b>Cache</b> warmed"
+	if have := digest.Message; want != have {
+		t.Fatalf("want Message=\n%s\nhave\n%s", want, have)
+	}
+}
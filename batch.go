@@ -0,0 +1,146 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBatchConcurrency is the default number of messages sent
+	// concurrently by SendBatch. Pushover asks to not use more than
+	// 2 concurrent connections to its API.
+	DefaultBatchConcurrency = 2
+)
+
+// ErrDeferred is returned in a BatchResult when the message was not sent
+// because it was deferred until the quiet hours of the recipient end.
+var ErrDeferred = errors.New("pushover: message deferred due to quiet hours")
+
+// Recipient of a message sent with SendBatch.
+type Recipient struct {
+	// UserKey is the user or group key of the recipient.
+	UserKey string
+	// Devices is the names of the devices (optional). If missing,
+	// the devices of the message are used.
+	Devices []string
+	// QuietHours of the recipient (optional).
+	QuietHours *QuietHours
+}
+
+// BatchOptions configure SendBatch.
+type BatchOptions struct {
+	// Concurrency is the maximum number of messages sent concurrently
+	// (default: DefaultBatchConcurrency).
+	Concurrency int
+}
+
+// BatchResult is the outcome of sending a message to a single recipient
+// with SendBatch.
+type BatchResult struct {
+	// Recipient of the message.
+	Recipient Recipient
+	// Response is the response of the API if the message was sent.
+	Response *SendResponse
+	// Err is the error if the message could not be sent.
+	Err error
+	// DeferredUntil is the time when the quiet hours of the recipient
+	// end, if the message was deferred. Err is ErrDeferred then.
+	DeferredUntil time.Time
+}
+
+// SendBatch sends the message to many recipients, each with their own
+// user key and optional devices. Messages are sent concurrently with at
+// most opts.Concurrency requests in flight; opts may be nil to use the
+// defaults.
+//
+// If a recipient has quiet hours, messages of Normal priority are
+// downgraded to Low priority during the quiet hours, or deferred. The
+// defaults of the client, e.g. its default priority, are applied before. Deferred
+// messages are not sent; their results contain ErrDeferred and the time
+// when the quiet hours end, so the caller can send them later.
+//
+// SendBatch honors the API limits: If the application has no remaining
+// API calls, the messages to the remaining recipients are not sent and
+// their results contain ErrQuotaExceeded.
+//
+// The results are returned in the order of recipients.
+func (api *messagesAPI) SendBatch(ctx context.Context, m Message, recipients []Recipient, opts *BatchOptions) []BatchResult {
+	concurrency := DefaultBatchConcurrency
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+
+	appToken, tokenErr := api.c.appTokenOf(m.App)
+	if m.Token != "" {
+		appToken, tokenErr = m.Token, nil
+	}
+
+	var (
+		results  = make([]BatchResult, len(recipients))
+		indices  = make(chan int)
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		exceeded bool
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				r := recipients[i]
+				results[i].Recipient = r
+
+				if tokenErr != nil {
+					results[i].Err = tokenErr
+					continue
+				}
+
+				mu.Lock()
+				skip := exceeded || api.c.quotaExceeded(appToken)
+				mu.Unlock()
+				if skip {
+					results[i].Err = ErrQuotaExceeded
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+
+				msg := m
+				msg.User = r.UserKey
+				if len(r.Devices) > 0 {
+					msg.Devices = r.Devices
+				}
+				// Resolve the default priority before the quiet hours
+				msg = api.c.applyDefaults(msg)
+				if r.QuietHours != nil {
+					now := time.Now()
+					var at time.Time
+					if msg, at = r.QuietHours.Apply(msg, now); at.After(now) {
+						results[i].Err = ErrDeferred
+						results[i].DeferredUntil = at
+						continue
+					}
+				}
+				resp, err := api.sendMessage(ctx, msg)
+				if IsStatusCode(err, http.StatusTooManyRequests) {
+					mu.Lock()
+					exceeded = true
+					mu.Unlock()
+				}
+				results[i].Response = resp
+				results[i].Err = err
+			}
+		}()
+	}
+	for i := range recipients {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return results
+}
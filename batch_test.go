@@ -0,0 +1,159 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMessagesSendBatch(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		devices  = make(map[string]string)
+		inflight int32
+		maxSeen  int32
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxSeen)
+			if n <= max || atomic.CompareAndSwapInt32(&maxSeen, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		user := r.FormValue("user")
+		mu.Lock()
+		devices[user] = r.FormValue("device")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":1,"request":"req-%s"}`, user)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recipients []Recipient
+	for i := 0; i < 20; i++ {
+		r := Recipient{UserKey: "user" + strconv.Itoa(i)}
+		if i%2 == 0 {
+			r.Devices = []string{"phone" + strconv.Itoa(i)}
+		}
+		recipients = append(recipients, r)
+	}
+	m := Message{Message: "Shift starts in 10 minutes", Devices: []string{"tablet"}}
+	results := client.Messages.SendBatch(context.Background(), m, recipients, &BatchOptions{Concurrency: 3})
+
+	if want, have := len(recipients), len(results); want != have {
+		t.Fatalf("want %d results, have %d", want, have)
+	}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatalf("result %d: want no error, have %v", i, res.Err)
+		}
+		if want, have := recipients[i].UserKey, res.Recipient.UserKey; want != have {
+			t.Fatalf("result %d: want recipient %q, have %q", i, want, have)
+		}
+		if want, have := "req-"+recipients[i].UserKey, res.Response.Request; want != have {
+			t.Fatalf("result %d: want request %q, have %q", i, want, have)
+		}
+		want := "tablet"
+		if i%2 == 0 {
+			want = "phone" + strconv.Itoa(i)
+		}
+		if have := devices[recipients[i].UserKey]; want != have {
+			t.Fatalf("result %d: want device %q, have %q", i, want, have)
+		}
+	}
+	if max := atomic.LoadInt32(&maxSeen); max > 3 {
+		t.Fatalf("want at most 3 concurrent requests, have %d", max)
+	}
+}
+
+func TestMessagesSendBatchQuotaExceeded(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":0,"errors":["message limit reached"]}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := []Recipient{{UserKey: "a"}, {UserKey: "b"}, {UserKey: "c"}}
+	results := client.Messages.SendBatch(context.Background(), Message{Message: "Hi"}, recipients, &BatchOptions{Concurrency: 1})
+	if want, have := int32(1), atomic.LoadInt32(&calls); want != have {
+		t.Fatalf("want %d API calls, have %d", want, have)
+	}
+	if !IsStatusCode(results[0].Err, http.StatusTooManyRequests) {
+		t.Fatalf("want status 429 for first result, have %v", results[0].Err)
+	}
+	for _, res := range results[1:] {
+		if want, have := ErrQuotaExceeded, res.Err; want != have {
+			t.Fatalf("want %v, have %v", want, have)
+		}
+	}
+}
+
+func TestMessagesSendBatchQuietHours(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		priorities = make(map[string]string)
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		priorities[r.FormValue("user")] = r.FormValue("priority")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":1,"request":"1"}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Quiet hours for the whole day
+	allDay := QuietHours{Location: time.UTC, Start: 0, End: 24 * time.Hour}
+	deferred := allDay
+	deferred.Action = QuietDefer
+	recipients := []Recipient{
+		{UserKey: "awake"},
+		{UserKey: "asleep", QuietHours: &allDay},
+		{UserKey: "later", QuietHours: &deferred},
+	}
+	results := client.Messages.SendBatch(context.Background(), Message{Message: "Hi"}, recipients, nil)
+
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("want no errors, have %v and %v", results[0].Err, results[1].Err)
+	}
+	if want, have := "", priorities["awake"]; want != have {
+		t.Fatalf("want priority=%q, have %q", want, have)
+	}
+	if want, have := "-1", priorities["asleep"]; want != have {
+		t.Fatalf("want priority=%q, have %q", want, have)
+	}
+	if _, found := priorities["later"]; found {
+		t.Fatal("want deferred message not to be sent")
+	}
+	if want, have := ErrDeferred, results[2].Err; want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if !results[2].DeferredUntil.After(time.Now()) {
+		t.Fatalf("want deferred until the future, have %v", results[2].DeferredUntil)
+	}
+}
@@ -0,0 +1,167 @@
+package pushover
+
+import (
+	"html"
+	"strings"
+	"time"
+)
+
+// MessageBuilder builds a Message with HTML formatting.
+//
+// All content passed to the builder is escaped, so it is safe to use
+// user-controlled strings. Use the Text, Bold, Italic, Underline, Color
+// and Link methods to compose the body with the subset of HTML supported
+// by Pushover, then call Build to get the Message.
+//
+// See https://pushover.net/api#html for details.
+type MessageBuilder struct {
+	m    Message
+	body strings.Builder
+}
+
+// NewMessageBuilder creates a new MessageBuilder.
+func NewMessageBuilder() *MessageBuilder {
+	return &MessageBuilder{}
+}
+
+// Title sets the title of the message.
+func (b *MessageBuilder) Title(title string) *MessageBuilder {
+	b.m.Title = title
+	return b
+}
+
+// Devices sets the names of the devices to send the message to.
+func (b *MessageBuilder) Devices(devices ...string) *MessageBuilder {
+	b.m.Devices = devices
+	return b
+}
+
+// URL sets the supplementary URL and its title.
+func (b *MessageBuilder) URL(url, title string) *MessageBuilder {
+	b.m.URL = url
+	b.m.URLTitle = title
+	return b
+}
+
+// Priority sets the priority of the message. Use Emergency to send
+// a message with Emergency priority.
+func (b *MessageBuilder) Priority(priority Priority) *MessageBuilder {
+	b.m.Priority = priority
+	return b
+}
+
+// Emergency sets the priority of the message to Emergency, with the
+// given retry and expire durations.
+func (b *MessageBuilder) Emergency(retry, expire time.Duration) *MessageBuilder {
+	b.m.Priority = Emergency
+	b.m.Retry = retry
+	b.m.Expire = expire
+	return b
+}
+
+// Sound sets the sound to be played.
+func (b *MessageBuilder) Sound(sound string) *MessageBuilder {
+	b.m.Sound = sound
+	return b
+}
+
+// Timestamp sets the date/time of the message.
+func (b *MessageBuilder) Timestamp(t time.Time) *MessageBuilder {
+	b.m.Timestamp = t
+	return b
+}
+
+// Attachment sets the name of a file to be attached.
+func (b *MessageBuilder) Attachment(filename string) *MessageBuilder {
+	b.m.Attachment = filename
+	return b
+}
+
+// CallbackURL sets the URL to invoke when the message is acknowledged.
+func (b *MessageBuilder) CallbackURL(url string) *MessageBuilder {
+	b.m.CallbackURL = url
+	return b
+}
+
+// Tags sets the tags of the message.
+func (b *MessageBuilder) Tags(tags ...string) *MessageBuilder {
+	b.m.Tags = tags
+	return b
+}
+
+// TTL sets the time to live of the message.
+func (b *MessageBuilder) TTL(ttl time.Duration) *MessageBuilder {
+	b.m.TTL = ttl
+	return b
+}
+
+// Text appends s to the body of the message.
+func (b *MessageBuilder) Text(s string) *MessageBuilder {
+	b.body.WriteString(html.EscapeString(s))
+	return b
+}
+
+// Newline appends a line break to the body of the message.
+func (b *MessageBuilder) Newline() *MessageBuilder {
+	b.body.WriteString("\n")
+	return b
+}
+
+// Bold appends s in bold to the body of the message.
+func (b *MessageBuilder) Bold(s string) *MessageBuilder {
+	return b.tag("b", s)
+}
+
+// Italic appends s in italics to the body of the message.
+func (b *MessageBuilder) Italic(s string) *MessageBuilder {
+	return b.tag("i", s)
+}
+
+// Underline appends s underlined to the body of the message.
+func (b *MessageBuilder) Underline(s string) *MessageBuilder {
+	return b.tag("u", s)
+}
+
+// Color appends s in the given color to the body of the message.
+// The color can be specified by name (e.g. "red") or in hex
+// notation (e.g. "#ff0000").
+func (b *MessageBuilder) Color(color, s string) *MessageBuilder {
+	b.body.WriteString(`<font color="`)
+	b.body.WriteString(html.EscapeString(color))
+	b.body.WriteString(`">`)
+	b.body.WriteString(html.EscapeString(s))
+	b.body.WriteString(`</font>`)
+	return b
+}
+
+// Link appends a link to href with the text s to the body of the message.
+// Only http, https and mailto links are allowed; for other schemes, e.g.
+// "javascript:", only the text s is appended.
+func (b *MessageBuilder) Link(href, s string) *MessageBuilder {
+	if !isSafeURL(href) {
+		b.body.WriteString(html.EscapeString(s))
+		return b
+	}
+	b.body.WriteString(`<a href="`)
+	b.body.WriteString(html.EscapeString(href))
+	b.body.WriteString(`">`)
+	b.body.WriteString(html.EscapeString(s))
+	b.body.WriteString(`</a>`)
+	return b
+}
+
+func (b *MessageBuilder) tag(name, s string) *MessageBuilder {
+	b.body.WriteString("<" + name + ">")
+	b.body.WriteString(html.EscapeString(s))
+	b.body.WriteString("</" + name + ">")
+	return b
+}
+
+// Build returns the Message with HTML formatting enabled.
+func (b *MessageBuilder) Build() Message {
+	m := b.m
+	m.Message = b.body.String()
+	m.HTML = true
+	m.Monospace = false
+	return m
+}
@@ -0,0 +1,54 @@
+package pushover
+
+import "testing"
+
+func TestMessageBuilder(t *testing.T) {
+	m := NewMessageBuilder().
+		Title("Deploy").
+		Priority(High).
+		Text("Service <api> ").
+		Bold("failed").
+		Text(" on ").
+		Italic("prod & staging").
+		Newline().
+		Underline("Status: ").
+		Color("#ff0000", "<down>").
+		Text(" ").
+		Link(`https://example.com/?a=1&b="2"`, "Details").
+		Build()
+
+	if !m.HTML {
+		t.Fatal("want HTML=true, have false")
+	}
+	if want, have := "Deploy", m.Title; want != have {
+		t.Fatalf("want Title=%q, have %q", want, have)
+	}
+	if want, have := High, m.Priority; want != have {
+		t.Fatalf("want Priority=%v, have %v", want, have)
+	}
+	want := "Service &lt;api&gt; <b>failed</b> on <i>prod &amp; staging</i>\n" +
+		`<u>Status: </u><font color="#ff0000">&lt;down&gt;</font> ` +
+		`<a href="https://example.com/?a=1&amp;b=&#34;2&#34;">Details</a>`
+	if have := m.Message; want != have {
+		t.Fatalf("want Message=\n%s\nhave\n%s", want, have)
+	}
+}
+
+func TestMessageBuilderUnsafeLink(t *testing.T) {
+	tests := []struct {
+		Href string
+		Want string
+	}{
+		{"javascript:alert(1)", "Details"},
+		{" JavaScript:alert(1)", "Details"},
+		{"data:text/html,<b>x</b>", "Details"},
+		{"mailto:ops@example.com", `<a href="mailto:ops@example.com">Details</a>`},
+		{"HTTPS://example.com", `<a href="HTTPS://example.com">Details</a>`},
+	}
+	for _, tt := range tests {
+		m := NewMessageBuilder().Link(tt.Href, "Details").Build()
+		if want, have := tt.Want, m.Message; want != have {
+			t.Fatalf("Link(%q): want Message=%q, have %q", tt.Href, want, have)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCallbackSize is the maximum size of the body of a callback request.
+const maxCallbackSize = 64 << 10
+
+// Callback is sent by Pushover to the CallbackURL of a message with
+// Emergency priority when the message is acknowledged.
+//
+// See https://pushover.net/api/receipts#callback for details.
+type Callback struct {
+	// Receipt of the acknowledged message.
+	Receipt string
+	// Acknowledged is true if the message was acknowledged.
+	Acknowledged bool
+	// AcknowledgedAt is the time when the message was acknowledged.
+	AcknowledgedAt time.Time
+	// AcknowledgedBy is the user key of the user that acknowledged
+	// the message.
+	AcknowledgedBy string
+	// AcknowledgedByDevice is the name of the device on which the user
+	// acknowledged the message.
+	AcknowledgedByDevice string
+}
+
+// Values returns the form values of the callback as sent by Pushover.
+func (cb Callback) Values() url.Values {
+	values := url.Values{}
+	values.Set("receipt", cb.Receipt)
+	if cb.Acknowledged {
+		values.Set("acknowledged", "1")
+	} else {
+		values.Set("acknowledged", "0")
+	}
+	if !cb.AcknowledgedAt.IsZero() {
+		values.Set("acknowledged_at", fmt.Sprint(cb.AcknowledgedAt.Unix()))
+	}
+	if cb.AcknowledgedBy != "" {
+		values.Set("acknowledged_by", cb.AcknowledgedBy)
+	}
+	if cb.AcknowledgedByDevice != "" {
+		values.Set("acknowledged_by_device", cb.AcknowledgedByDevice)
+	}
+	return values
+}
+
+// ParseCallback parses the callback sent by Pushover in r.
+func ParseCallback(r *http.Request) (Callback, error) {
+	if err := r.ParseForm(); err != nil {
+		return Callback{}, fmt.Errorf("pushover: invalid callback: %w", err)
+	}
+	cb := Callback{
+		Receipt:              r.PostForm.Get("receipt"),
+		AcknowledgedBy:       r.PostForm.Get("acknowledged_by"),
+		AcknowledgedByDevice: r.PostForm.Get("acknowledged_by_device"),
+	}
+	if cb.Receipt == "" {
+		return Callback{}, fmt.Errorf("pushover: invalid callback: missing receipt")
+	}
+	if v := r.PostForm.Get("acknowledged"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return Callback{}, fmt.Errorf("pushover: invalid callback: acknowledged=%q", v)
+		}
+		cb.Acknowledged = i != 0
+	}
+	if v := r.PostForm.Get("acknowledged_at"); v != "" {
+		secs, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return Callback{}, fmt.Errorf("pushover: invalid callback: acknowledged_at=%q", v)
+		}
+		cb.AcknowledgedAt = unixTime(secs)
+	}
+	return cb, nil
+}
+
+// CallbackHandler is a http.Handler that receives the callbacks sent by
+// Pushover to the CallbackURL of messages with Emergency priority, and
+// dispatches them to a function.
+//
+// The handler responds with 400 Bad Request if the callback is invalid,
+// and with 500 Internal Server Error if the function returns an error.
+type CallbackHandler struct {
+	fn func(context.Context, Callback) error
+}
+
+// NewCallbackHandler creates a CallbackHandler that invokes fn for every
+// callback received.
+func NewCallbackHandler(fn func(context.Context, Callback) error) *CallbackHandler {
+	return &CallbackHandler{fn: fn}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxCallbackSize)
+	cb, err := ParseCallback(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.fn(r.Context(), cb); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// NewCallbackRequest returns a request like the one Pushover sends to
+// the CallbackURL at target. It is useful to test a CallbackHandler,
+// e.g. with the net/http/httptest package.
+func NewCallbackRequest(target string, cb Callback) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(cb.Values().Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// SimulateCallback sends cb to the CallbackURL at target, like Pushover
+// does when a message is acknowledged. It is useful to test callbacks
+// locally, where Pushover cannot reach the CallbackURL.
+func SimulateCallback(ctx context.Context, target string, cb Callback) error {
+	req, err := NewCallbackRequest(target, cb)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("unable to send callback: %w", err)
+	}
+	defer closeBody(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pushover: callback failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
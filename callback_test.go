@@ -0,0 +1,92 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	var have Callback
+	h := NewCallbackHandler(func(ctx context.Context, cb Callback) error {
+		have = cb
+		return nil
+	})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	want := Callback{
+		Receipt:              "r123",
+		Acknowledged:         true,
+		AcknowledgedAt:       time.Unix(1600000000, 0),
+		AcknowledgedBy:       "user",
+		AcknowledgedByDevice: "phone",
+	}
+	if err := SimulateCallback(context.Background(), ts.URL, want); err != nil {
+		t.Fatal(err)
+	}
+	if have.Receipt != want.Receipt || have.Acknowledged != want.Acknowledged ||
+		!have.AcknowledgedAt.Equal(want.AcknowledgedAt) ||
+		have.AcknowledgedBy != want.AcknowledgedBy ||
+		have.AcknowledgedByDevice != want.AcknowledgedByDevice {
+		t.Fatalf("want %+v, have %+v", want, have)
+	}
+}
+
+func TestCallbackHandlerErrors(t *testing.T) {
+	h := NewCallbackHandler(func(ctx context.Context, cb Callback) error {
+		if cb.Receipt == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	tests := []struct {
+		Name   string
+		Method string
+		Body   string
+		Status int
+	}{
+		{"GET", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"MissingReceipt", http.MethodPost, "acknowledged=1", http.StatusBadRequest},
+		{"InvalidAcknowledged", http.MethodPost, "receipt=r1&acknowledged=yes", http.StatusBadRequest},
+		{"InvalidAcknowledgedAt", http.MethodPost, "receipt=r1&acknowledged=1&acknowledged_at=now", http.StatusBadRequest},
+		{"HandlerFails", http.MethodPost, "receipt=fail&acknowledged=1", http.StatusInternalServerError},
+		{"OK", http.MethodPost, "receipt=r1&acknowledged=1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.Method, "/callback", strings.NewReader(tt.Body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if want, have := tt.Status, w.Code; want != have {
+				t.Fatalf("want status %d, have %d", want, have)
+			}
+		})
+	}
+}
+
+func TestNewCallbackRequest(t *testing.T) {
+	req, err := NewCallbackRequest("http://localhost/callback", Callback{Receipt: "r1", Acknowledged: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := ParseCallback(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "r1", cb.Receipt; want != have {
+		t.Fatalf("want receipt %q, have %q", want, have)
+	}
+	if !cb.Acknowledged {
+		t.Fatal("want callback to be acknowledged")
+	}
+	if !cb.AcknowledgedAt.IsZero() {
+		t.Fatalf("want zero acknowledged at, have %v", cb.AcknowledgedAt)
+	}
+}
@@ -1,11 +1,15 @@
 package pushover
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"runtime"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -24,14 +28,29 @@ type Client struct {
 	appToken string
 	userKey  string
 	logger   Logger
+	metrics  Metrics
+	tracer   Tracer
 	ua       string
+	mws      []Middleware
+	apps     map[string]string // name -> App Token of named applications
 
-	appLimit     int64 // # of available API calls (as reported by the last API call)
-	appRemaining int64 // # of remaining API calls (as reported by the last API call)
-	appReset     int64 // date/time (Unix epoch) when the API call limits are reset
+	configFile string   // path of the configuration file, if any
+	profile    string   // name of the profile in the configuration file
+	device     string   // default device
+	sound      string   // default sound
+	priority   Priority // default priority
+
+	creds  CredentialsProvider
+	credMu sync.RWMutex // guards appToken and userKey if creds is set
+
+	mu     sync.RWMutex      // guards limits
+	limits map[string]Limits // App Token -> limits as reported by the last API call
 
 	// Messages allows e.g. sending a notification.
 	Messages *messagesAPI
+	// Receipts allows e.g. polling and canceling messages of
+	// Emergency priority.
+	Receipts *receiptsAPI
 }
 
 // ClientOption for configuring Client settings.
@@ -43,24 +62,69 @@ type ClientOption func(*Client)
 // defaults from these environment variables (options override
 // the environment):
 //
-//   APP_TOKEN      (default: "")
-//   USER_KEY       (default: "")
-//   PUSHOVER_URL   (default: "https://api.pushover.net")
+//	PUSHOVER_APP_TOKEN       (default: "")
+//	PUSHOVER_USER_KEY        (default: "")
+//	PUSHOVER_APP_TOKEN_FILE  (default: "")
+//	PUSHOVER_USER_KEY_FILE   (default: "")
+//	PUSHOVER_URL             (default: "https://api.pushover.net")
+//	PUSHOVER_DEVICE          (default: "")
+//	PUSHOVER_SOUND           (default: "")
+//	PUSHOVER_PRIORITY        (default: "normal")
+//
+// For compatibility, the legacy names APP_TOKEN, USER_KEY,
+// APP_TOKEN_FILE and USER_KEY_FILE are used if the namespaced
+// environment variables are not set; see EnvVars.
+//
+// If a configuration file or profile is specified with WithConfigFile
+// or WithProfile, the environment overrides the profile. The files in
+// PUSHOVER_APP_TOKEN_FILE and PUSHOVER_USER_KEY_FILE are only read for
+// credentials not given by WithAppToken or WithUserKey. To read the
+// credentials e.g. from a keyring, use WithCredentialsProvider.
 func NewClient(options ...ClientOption) (*Client, error) {
 	c := &Client{
-		tr:       http.DefaultTransport,
-		baseURL:  defaultBaseURL,
-		appToken: envString("", "APP_TOKEN"),
-		userKey:  envString("", "USER_KEY"),
-		ua:       fmt.Sprintf("pushover-go-api/%s (%s/%s; Go %s)", Version, runtime.GOOS, runtime.GOARCH, runtime.Version()),
+		tr:     http.DefaultTransport,
+		ua:     fmt.Sprintf("pushover-go-api/%s (%s/%s; Go %s)", Version, runtime.GOOS, runtime.GOARCH, runtime.Version()),
+		apps:   make(map[string]string),
+		limits: make(map[string]Limits),
 	}
 	for _, o := range options {
 		o(c)
 	}
+	p, err := c.loadProfile()
+	if err != nil {
+		return nil, err
+	}
+	if p.URL == "" {
+		p.URL = defaultBaseURL
+	}
 	if c.baseURL == "" {
-		c.baseURL = envString(defaultBaseURL, "PUSHOVER_URL")
+		c.baseURL = EnvURL.String(p.URL)
+	}
+	if c.creds == nil {
+		// Files are only read for credentials not given as options
+		var appTokenFile, userKeyFile string
+		if c.appToken == "" {
+			appTokenFile = EnvAppTokenFile.String("")
+		}
+		if c.userKey == "" {
+			userKeyFile = EnvUserKeyFile.String("")
+		}
+		if appTokenFile != "" || userKeyFile != "" {
+			c.creds = NewFileCredentials(appTokenFile, userKeyFile)
+		}
+	}
+	if c.appToken == "" {
+		c.appToken = EnvAppToken.String(p.AppToken)
+	}
+	if c.userKey == "" {
+		c.userKey = EnvUserKey.String(p.UserKey)
+	}
+	c.device = EnvDevice.String(p.Device)
+	c.sound = EnvSound.String(p.Sound)
+	c.priority, err = parseDefaultPriority(EnvPriority.String(p.Priority))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", EnvPriority.Name, err)
 	}
-	var err error
 	c.url, err = url.Parse(c.baseURL)
 	if err != nil {
 		return nil, err
@@ -68,7 +132,17 @@ func NewClient(options ...ClientOption) (*Client, error) {
 	if c.logger == nil {
 		c.logger = &nopLogger{}
 	}
+	if c.metrics == nil {
+		c.metrics = nopMetrics{}
+	}
+	if c.tracer == nil {
+		c.tracer = nopTracer{}
+	}
+	for i := len(c.mws) - 1; i >= 0; i-- {
+		c.tr = c.mws[i](c.tr)
+	}
 	c.Messages = &messagesAPI{c: c}
+	c.Receipts = &receiptsAPI{c: c}
 	return c, nil
 }
 
@@ -85,6 +159,15 @@ func WithTransport(tr http.RoundTripper) ClientOption {
 	}
 }
 
+// WithMiddleware adds middleware around the transport of the Client,
+// e.g. RequestIDMiddleware. Middleware is applied in the order given,
+// i.e. the first middleware sees the request first.
+func WithMiddleware(mw ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.mws = append(c.mws, mw...)
+	}
+}
+
 // WithURL specifies the base URL to use.
 // It is primarily used in development or testing.
 func WithURL(url string) ClientOption {
@@ -107,6 +190,16 @@ func WithUserKey(userKey string) ClientOption {
 	}
 }
 
+// WithApp registers an application with the given name and App Token.
+// Use Message.App to send a message with the App Token of a registered
+// application, e.g. to get a distinct icon for different kinds of
+// notifications.
+func WithApp(name, appToken string) ClientOption {
+	return func(c *Client) {
+		c.apps[name] = appToken
+	}
+}
+
 // WithLogger specifies a new logger.
 func WithLogger(logger Logger) ClientOption {
 	return func(c *Client) {
@@ -131,39 +224,168 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	}
 
+	// Record details and timings of the request
+	ctx := req.Context()
+	ti := TraceInfoFromContext(ctx)
+	if ti == nil {
+		ti = &TraceInfo{Endpoint: endpointOf(req.URL.Path), Attempt: attemptOf(ctx)}
+		ctx = context.WithValue(ctx, traceInfoContextKey{}, ti)
+	}
 	start := time.Now()
+	req = req.WithContext(httptrace.WithClientTrace(ctx, ti.clientTrace(start)))
+
 	resp, err := c.tr.RoundTrip(req)
 	duration := time.Since(start)
 
-	if v := resp.Header.Get(http.CanonicalHeaderKey("X-Limit-App-Limit")); v != "" {
-		c.appLimit, _ = strconv.ParseInt(v, 10, 64)
-	}
-	if v := resp.Header.Get(http.CanonicalHeaderKey("X-Limit-App-Remaining")); v != "" {
-		c.appRemaining, _ = strconv.ParseInt(v, 10, 64)
-	}
-	if v := resp.Header.Get(http.CanonicalHeaderKey("X-Limit-App-Reset")); v != "" {
-		c.appReset, _ = strconv.ParseInt(v, 10, 64)
+	statusCode := 0
+	if err == nil && resp != nil {
+		statusCode = resp.StatusCode
+		ti.StatusCode = statusCode
+		appToken := c.currentAppToken()
+		if v, ok := req.Context().Value(appTokenContextKey{}).(string); ok {
+			appToken = v
+		}
+		c.updateLimits(appToken, resp.Header)
 	}
+	c.metrics.ObserveRequest(endpointOf(req.URL.Path), statusCode, duration)
 
 	c.logger.Log(req, resp, err, start, duration)
 
 	return resp, err
 }
 
+// appTokenContextKey is the context key for the App Token used in a request.
+type appTokenContextKey struct{}
+
+// updateLimits updates the limits of the application with the given
+// App Token from the X-Limit-App-* headers of a response.
+func (c *Client) updateLimits(appToken string, header http.Header) {
+	c.mu.Lock()
+	l := c.limits[appToken]
+	found := parseLimits(header, &l)
+	if found {
+		c.limits[appToken] = l
+	}
+	c.mu.Unlock()
+
+	// Metrics may ask the client for its limits, so call it without the lock
+	if found {
+		if l.Reset > 0 {
+			l.ResetTime = time.Unix(l.Reset, 0)
+		}
+		c.metrics.SetLimits(c.appNameOf(appToken), l)
+	}
+}
+
+// appOf returns the name of the application registered with the given
+// App Token. If several applications are registered with the same App
+// Token, the first name in sorted order is returned.
+func (c *Client) appOf(appToken string) (string, bool) {
+	for _, name := range c.Apps() {
+		if c.apps[name] == appToken {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// appNameOf returns the name of the application with the given App
+// Token as registered with WithApp, "default" for the App Token of the
+// client, or "unregistered" for any other App Token, e.g. of Message.Token.
+func (c *Client) appNameOf(appToken string) string {
+	if name, found := c.appOf(appToken); found {
+		return name
+	}
+	if appToken == c.currentAppToken() {
+		return "default"
+	}
+	return "unregistered"
+}
+
+// parseLimits updates l from the X-Limit-App-* headers of a response.
+// It returns false if none of the headers is present.
+func parseLimits(header http.Header, l *Limits) bool {
+	var found bool
+	if v := header.Get(http.CanonicalHeaderKey("X-Limit-App-Limit")); v != "" {
+		l.Limit, _ = strconv.ParseInt(v, 10, 64)
+		found = true
+	}
+	if v := header.Get(http.CanonicalHeaderKey("X-Limit-App-Remaining")); v != "" {
+		l.Remaining, _ = strconv.ParseInt(v, 10, 64)
+		found = true
+	}
+	if v := header.Get(http.CanonicalHeaderKey("X-Limit-App-Reset")); v != "" {
+		l.Reset, _ = strconv.ParseInt(v, 10, 64)
+		found = true
+	}
+	if l.Reset > 0 {
+		l.ResetTime = time.Unix(l.Reset, 0)
+	}
+	return found
+}
+
 // Limits returns the API limits as reported by the last API call.
 // If you want to know the current limits without relying on the last
 // API call, use Messages.Limits instead.
 //
+// Limits reports the limits of the App Token of the client. Use
+// AppLimits to get the limits of an application registered with WithApp.
+//
 // See https://pushover.net/api#limits for details.
 func (c *Client) Limits() Limits {
-	var t time.Time
-	if c.appReset > 0 {
-		t = time.Unix(c.appLimit, 0)
+	return c.limitsOf(c.currentAppToken())
+}
+
+// AppLimits returns the API limits of the application registered
+// with the given name, as reported by the last API call of that
+// application. If name is empty, the limits of the App Token of
+// the client are returned.
+func (c *Client) AppLimits(name string) Limits {
+	appToken, err := c.appTokenOf(name)
+	if err != nil {
+		return Limits{}
 	}
-	return Limits{
-		Limit:     c.appLimit,
-		Remaining: c.appLimit,
-		Reset:     c.appReset,
-		ResetTime: t,
+	return c.limitsOf(appToken)
+}
+
+// Apps returns the names of the applications registered with WithApp,
+// in sorted order.
+func (c *Client) Apps() []string {
+	names := make([]string, 0, len(c.apps))
+	for name := range c.apps {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+// appTokenOf returns the App Token of the application with the given
+// name, or the App Token of the client if name is empty.
+func (c *Client) appTokenOf(name string) (string, error) {
+	if name == "" {
+		return c.currentAppToken(), nil
+	}
+	appToken, ok := c.apps[name]
+	if !ok {
+		return "", fmt.Errorf("pushover: unknown app %q", name)
+	}
+	return appToken, nil
+}
+
+func (c *Client) limitsOf(appToken string) Limits {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	l := c.limits[appToken]
+	if l.Reset > 0 {
+		l.ResetTime = time.Unix(l.Reset, 0)
+	}
+	return l
+}
+
+// quotaExceeded returns true if the last API call of the application
+// with the given App Token reported that there are no remaining API
+// calls and the limits are not yet reset.
+func (c *Client) quotaExceeded(appToken string) bool {
+	l := c.limitsOf(appToken)
+	return l.Limit > 0 && l.Remaining <= 0 && time.Now().Unix() < l.Reset
 }
@@ -1,6 +1,14 @@
 package pushover
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
 
 func TestClientDefaults(t *testing.T) {
 	c, err := NewClient(WithAppToken("DEADBEEF"))
@@ -14,3 +22,123 @@ func TestClientDefaults(t *testing.T) {
 		t.Fatalf("expected AppToken=%q, got %q", want, have)
 	}
 }
+
+func TestClientLimits(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "7496")
+		w.Header().Set("X-Limit-App-Reset", "1393653600")
+		w.Write([]byte(`{"status":1,"request":"647d2300-702c-4b38-8b2f-d56326ae460b"}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Build running"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	limits := client.Limits()
+	if want, have := int64(10000), limits.Limit; want != have {
+		t.Fatalf("want Limit=%d, have %d", want, have)
+	}
+	if want, have := int64(7496), limits.Remaining; want != have {
+		t.Fatalf("want Remaining=%d, have %d", want, have)
+	}
+	if want, have := int64(1393653600), limits.Reset; want != have {
+		t.Fatalf("want Reset=%d, have %d", want, have)
+	}
+	if want, have := time.Unix(1393653600, 0), limits.ResetTime; !want.Equal(have) {
+		t.Fatalf("want ResetTime=%v, have %v", want, have)
+	}
+}
+
+func TestClientApps(t *testing.T) {
+	var tokens []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.FormValue("token")
+		tokens = append(tokens, token)
+		switch token {
+		case "deploys-token":
+			w.Header().Set("X-Limit-App-Limit", "10000")
+			w.Header().Set("X-Limit-App-Remaining", "9000")
+			w.Header().Set("X-Limit-App-Reset", "1893456000")
+		default:
+			w.Header().Set("X-Limit-App-Limit", "10000")
+			w.Header().Set("X-Limit-App-Remaining", "42")
+			w.Header().Set("X-Limit-App-Reset", "1893456000")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":1,"request":"1"}`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(
+		WithURL(ts.URL),
+		WithAppToken("default-token"),
+		WithUserKey("user"),
+		WithApp("deploys", "deploys-token"),
+		WithApp("billing", "billing-token"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"billing", "deploys"}, c.Apps(); !reflect.DeepEqual(want, have) {
+		t.Fatalf("want Apps=%v, have %v", want, have)
+	}
+
+	ctx := context.Background()
+	if _, err := c.Messages.Send(ctx, Message{Message: "Deployed", App: "deploys"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Messages.Send(ctx, Message{Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Messages.Send(ctx, Message{Message: "Unknown", App: "security"}); err == nil {
+		t.Fatal("want error for unknown app, have nil")
+	}
+	if want, have := []string{"deploys-token", "default-token"}, tokens; !reflect.DeepEqual(want, have) {
+		t.Fatalf("want tokens=%v, have %v", want, have)
+	}
+
+	if want, have := int64(9000), c.AppLimits("deploys").Remaining; want != have {
+		t.Fatalf("want remaining=%d for deploys, have %d", want, have)
+	}
+	if want, have := int64(42), c.Limits().Remaining; want != have {
+		t.Fatalf("want remaining=%d, have %d", want, have)
+	}
+	if want, have := int64(0), c.AppLimits("billing").Limit; want != have {
+		t.Fatalf("want limit=%d for billing, have %d", want, have)
+	}
+	if want, have := time.Unix(1893456000, 0), c.AppLimits("deploys").ResetTime; !want.Equal(have) {
+		t.Fatalf("want reset time %v, have %v", want, have)
+	}
+}
+
+// failingTransport is a http.RoundTripper that always fails.
+type failingTransport struct {
+	err error
+}
+
+func (tr failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, tr.err
+}
+
+func TestClientDoTransportErrorKeepsLimits(t *testing.T) {
+	failure := errors.New("connection refused")
+	c, err := NewClient(WithURL("http://localhost"), WithAppToken("token"), WithUserKey("user"), WithTransport(failingTransport{err: failure}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.updateLimits("token", http.Header{"X-Limit-App-Limit": {"10000"}, "X-Limit-App-Remaining": {"42"}})
+
+	_, err = c.Messages.Send(context.Background(), Message{Message: "Hello"})
+	if !errors.Is(err, failure) {
+		t.Fatalf("want %v, have %v", failure, err)
+	}
+	if want, have := int64(42), c.Limits().Remaining; want != have {
+		t.Fatalf("want remaining=%d, have %d", want, have)
+	}
+}
@@ -8,6 +8,14 @@ import (
 	"github.com/olivere/pushover-api-go"
 )
 
+// cliEnvVars are the environment variables of the command line client,
+// in addition to the ones of the library.
+var cliEnvVars = []pushover.EnvVar{
+	{Name: "PUSHOVER_PROFILE", Description: "Profile of the configuration file"},
+	{Name: "PUSHOVER_TEMPLATE_DIR", Description: "Directory to load templates from"},
+	{Name: "PUSHOVER_QUEUE_DIR", Description: "Spool directory of the queue"},
+}
+
 func runEnv(_ *pushover.Client, args []string) error {
 	fs := flag.NewFlagSet("env", flag.ExitOnError)
 	fs.Usage = func() {
@@ -18,13 +26,41 @@ func runEnv(_ *pushover.Client, args []string) error {
 		flag.PrintDefaults()
 		fmt.Fprintln(w)
 	}
+	var (
+		reveal = fs.Bool("reveal", false, "Print secrets instead of masking them")
+	)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	fmt.Printf("%-30s: %s (required)\n", "APP_TOKEN", envString("", "APP_TOKEN"))
-	fmt.Printf("%-30s: %s (required)\n", "USER_KEY", envString("", "USER_KEY"))
-	fmt.Printf("%-30s: %s (required)\n", "PUSHOVER_URL", envString("", "PUSHOVER_URL"))
+	for _, v := range append(pushover.EnvVars(), cliEnvVars...) {
+		value, source := v.Lookup()
+		switch {
+		case source == "":
+			value = "(not set)"
+		case v.Secret && !*reveal:
+			value = mask(value)
+		}
+		if source != "" {
+			value += fmt.Sprintf(" (from %s)", source)
+		}
+		fmt.Printf("%-30s: %s\n", v.Name, value)
+	}
 
 	return nil
 }
+
+// mask masks a secret with a fixed placeholder, which hides its length.
+// The last characters of long secrets are kept, so they can still be
+// told apart from others.
+func mask(s string) string {
+	const (
+		placeholder = "****"
+		keep        = 4
+	)
+	runes := []rune(s)
+	if len(runes) < 3*keep {
+		return placeholder
+	}
+	return placeholder + string(runes[len(runes)-keep:])
+}
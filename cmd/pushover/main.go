@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"os"
@@ -16,7 +17,10 @@ import (
 var (
 	insecure = flag.Bool("k", false, "Accept insecure connections")
 	verbose  = flag.Bool("v", false, "Verbose output to stderr")
+	logFmt   = flag.String("log-format", "text", "Format of verbose output (text or json)")
 	raw      = flag.Bool("d", false, "Raw output of HTTP request/response to stderr")
+	config   = flag.String("config", "", "Configuration file (default is config.json in the user config dir, if present)")
+	profile  = flag.String("profile", envString("", "PUSHOVER_PROFILE"), "Profile of the configuration file to use (optional)")
 )
 
 func main() {
@@ -36,6 +40,16 @@ func runMain() error {
 
 	// Set up client
 	options := []pushover.ClientOption{}
+	if *config != "" {
+		options = append(options, pushover.WithConfigFile(*config))
+	} else if path, err := pushover.DefaultConfigFile(); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			options = append(options, pushover.WithConfigFile(path))
+		}
+	}
+	if *profile != "" {
+		options = append(options, pushover.WithProfile(*profile))
+	}
 	if *insecure {
 		tr := &http.Transport{
 			TLSClientConfig: &tls.Config{
@@ -45,7 +59,16 @@ func runMain() error {
 		options = append(options, pushover.WithTransport(tr))
 	}
 	if *verbose {
-		options = append(options, pushover.WithLogger(pushover.NewJSONLogger(os.Stderr)))
+		var h slog.Handler
+		switch *logFmt {
+		case "text":
+			h = slog.NewTextHandler(os.Stderr, nil)
+		case "json":
+			h = slog.NewJSONHandler(os.Stderr, nil)
+		default:
+			return fmt.Errorf("invalid log format %q", *logFmt)
+		}
+		options = append(options, pushover.WithLogger(pushover.NewSlogLogger(slog.New(h))))
 	} else if *raw {
 		options = append(options, pushover.WithLogger(pushover.NewRawLogger(os.Stderr)))
 	}
@@ -65,6 +88,8 @@ func runMain() error {
 		return runMessages(client, flag.Args()[1:])
 	case "send":
 		return runMessagesSend(client, flag.Args()[1:])
+	case "queue":
+		return runQueue(client, flag.Args()[1:])
 	}
 	return nil
 }
@@ -79,6 +104,7 @@ func usage() {
 	fmt.Fprint(w, "Commands:\n")
 	fmt.Fprint(w, "  env          Print environment\n")
 	fmt.Fprint(w, "  send         Send a message\n")
+	fmt.Fprint(w, "  queue        Show (list) or drain (flush) the queue of outbound messages\n")
 	fmt.Fprintln(w)
 }
 
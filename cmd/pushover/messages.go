@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -39,43 +41,45 @@ func runMessagesSend(client *pushover.Client, args []string) error {
 		fs.PrintDefaults()
 	}
 	var (
-		message    = fs.String("m", "", "Message to send")
-		html       = fs.Bool("html", false, "Message is formatted as HTML")
-		mono       = fs.Bool("mono", false, "Use monospace font for message")
-		title      = fs.String("t", "", "Title (optional)")
-		device     = fs.String("d", "", "Device (optional)")
-		url        = fs.String("url", "", "URL (optional)")
-		urlTitle   = fs.String("url-title", "", "URL title (optional)")
-		priority   = fs.String("p", "", "Priority (lowest,low,normal,high, or emergency)")
-		sound      = fs.String("sound", "", "Sound to play (optional)")
-		attachment = fs.String("a", "", "File to attach (optional)")
-		retry      = fs.Duration("retry", 30*time.Second, "Retry duration (if priority is emergency)")
-		expire     = fs.Duration("expire", 5*time.Minute, "Expire duration (if priority is emergency)")
-		tags       = fs.String("tags", "", "Tags (optional)")
+		message     = fs.String("m", "", "Message to send")
+		html        = fs.Bool("html", false, "Message is formatted as HTML")
+		markdown    = fs.Bool("markdown", false, "Message is formatted as Markdown and converted to HTML")
+		mono        = fs.Bool("mono", false, "Use monospace font for message")
+		title       = fs.String("t", "", "Title (optional)")
+		device      = fs.String("d", "", "Device (optional)")
+		url         = fs.String("url", "", "URL (optional)")
+		urlTitle    = fs.String("url-title", "", "URL title (optional)")
+		priority    = fs.String("p", "", "Priority (lowest,low,normal,high, or emergency)")
+		sound       = fs.String("sound", "", "Sound to play (optional)")
+		attachment  = fs.String("a", "", "File to attach (optional)")
+		retry       = fs.Duration("retry", 30*time.Second, "Retry duration (if priority is emergency)")
+		expire      = fs.Duration("expire", 5*time.Minute, "Expire duration (if priority is emergency)")
+		tags        = fs.String("tags", "", "Tags (optional)")
+		template    = fs.String("template", "", "Name of the template to render the message with (optional)")
+		templateDir = fs.String("template-dir", envString("templates", "PUSHOVER_TEMPLATE_DIR"), "Directory to load templates from")
+		data        = fs.String("data", "", "JSON file with data to render the template with (optional, use - for stdin)")
+		user        = fs.String("user", "", "User or group key to send to (optional, overrides PUSHOVER_USER_KEY)")
+		token       = fs.String("token", "", "App token to send with (optional, overrides PUSHOVER_APP_TOKEN)")
+		split       = fs.Bool("split", false, "Split long messages into multiple numbered parts instead of truncating them")
+		ttl         = fs.Duration("ttl", 0, "Time to live before the message is deleted from devices (optional, not supported if priority is emergency)")
 	)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	prio := pushover.Normal
-	switch *priority {
-	default:
-		prio = pushover.Normal
-	case "lowest":
-		prio = pushover.Lowest
-	case "low":
-		prio = pushover.Low
-	case "normal":
-		prio = pushover.Normal
-	case "high":
-		prio = pushover.High
-	case "emergency":
-		prio = pushover.Emergency
+	prio, err := pushover.ParsePriority(*priority)
+	if err != nil {
+		return err
+	}
+	if *priority == "" {
+		// Use the default priority of the profile, if any
+		prio = pushover.DefaultPriority
 	}
 
 	msg := pushover.Message{
 		Message:    *message,
 		HTML:       *html,
+		Markdown:   *markdown,
 		Monospace:  *mono,
 		Title:      *title,
 		URL:        *url,
@@ -85,6 +89,9 @@ func runMessagesSend(client *pushover.Client, args []string) error {
 		Expire:     *expire,
 		Sound:      *sound,
 		Attachment: *attachment,
+		TTL:        *ttl,
+		User:       *user,
+		Token:      *token,
 	}
 	if v := *device; v != "" {
 		msg.Devices = []string{v}
@@ -92,6 +99,19 @@ func runMessagesSend(client *pushover.Client, args []string) error {
 	if v := *tags; v != "" {
 		msg.Tags = []string{v}
 	}
+	if v := *template; v != "" {
+		msg, err = executeTemplate(*templateDir, v, *data, msg)
+		if err != nil {
+			return err
+		}
+	}
+	if *split {
+		responses, err := client.Messages.SendSplit(context.Background(), msg)
+		for _, resp := range responses {
+			fmt.Println(resp.Receipt)
+		}
+		return err
+	}
 	resp, err := client.Messages.Send(context.Background(), msg)
 	if err != nil {
 		return err
@@ -101,6 +121,31 @@ func runMessagesSend(client *pushover.Client, args []string) error {
 	return nil
 }
 
+// executeTemplate renders msg with the template name loaded from dir,
+// using the JSON data in dataFile.
+func executeTemplate(dir, name, dataFile string, msg pushover.Message) (pushover.Message, error) {
+	templates, err := pushover.LoadTemplates(dir)
+	if err != nil {
+		return msg, err
+	}
+	var data interface{}
+	if dataFile != "" {
+		var r io.Reader = os.Stdin
+		if dataFile != "-" {
+			f, err := os.Open(dataFile)
+			if err != nil {
+				return msg, err
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := json.NewDecoder(r).Decode(&data); err != nil {
+			return msg, fmt.Errorf("unable to decode data: %w", err)
+		}
+	}
+	return templates.Execute(name, msg, data)
+}
+
 func runMessagesLimits(client *pushover.Client, args []string) error {
 	fs := flag.NewFlagSet("limits", flag.ExitOnError)
 	fs.Usage = func() {
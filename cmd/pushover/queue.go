@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/olivere/pushover-api-go"
+)
+
+func runQueue(client *pushover.Client, args []string) error {
+	fs := flag.NewFlagSet("queue", flag.ExitOnError)
+	fs.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintf(w, "Usage of %s %s [list|flush]:\n", os.Args[0], fs.Name())
+		fs.PrintDefaults()
+	}
+	var (
+		dir = fs.String("dir", envString(defaultQueueDir(), "PUSHOVER_QUEUE_DIR"), "Spool directory of the queue")
+	)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	q, err := pushover.NewQueue(client, *dir, pushover.WithQueueBackground(false))
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	switch fs.Arg(0) {
+	default:
+		return fmt.Errorf("unsupported call: %s", fs.Arg(0))
+	case "", "list":
+		return runQueueList(q)
+	case "flush":
+		return q.Flush(context.Background())
+	}
+}
+
+func runQueueList(q *pushover.Queue) error {
+	entries, err := q.Pending()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		state := "pending"
+		if e.Failed {
+			state = "failed"
+		}
+		fmt.Printf("%s %s %-7s attempts=%d title=%q message=%q",
+			e.ID, e.EnqueuedAt.Format(time.RFC3339), state, e.Attempts,
+			e.Message.Title, pushover.Truncate(e.Message.Message, 40, "…"))
+		if e.LastError != "" {
+			fmt.Printf(" error=%q", e.LastError)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// defaultQueueDir returns the default spool directory of the queue.
+func defaultQueueDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "pushover", "queue")
+}
@@ -0,0 +1,199 @@
+package pushover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Config is the configuration file of Pushover clients. It holds named
+// profiles, e.g. "work" and "personal", each with their own credentials
+// and defaults.
+//
+// The configuration file is in JSON, e.g.:
+//
+//	{
+//		"default_profile": "personal",
+//		"profiles": {
+//			"personal": {
+//				"app_token": "...",
+//				"user_key": "..."
+//			},
+//			"work": {
+//				"app_token": "...",
+//				"user_key": "...",
+//				"device": "phone",
+//				"sound": "siren",
+//				"priority": "high"
+//			}
+//		}
+//	}
+//
+// As the configuration file contains secrets, it should only be
+// readable by its owner.
+type Config struct {
+	// DefaultProfile is the name of the profile to use if none is
+	// specified. If empty, the profile named "default" is used.
+	DefaultProfile string `json:"default_profile,omitempty"`
+	// Profiles by name.
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// Profile holds the credentials and defaults of a named profile.
+type Profile struct {
+	// AppToken is the App Token to use.
+	AppToken string `json:"app_token,omitempty"`
+	// UserKey is the user or group key to send messages to.
+	UserKey string `json:"user_key,omitempty"`
+	// URL is the base URL of the Pushover API.
+	URL string `json:"url,omitempty"`
+	// Device is the default device to send messages to.
+	Device string `json:"device,omitempty"`
+	// Sound is the default sound of messages.
+	Sound string `json:"sound,omitempty"`
+	// Priority is the default priority of messages, e.g. "high". It is
+	// applied to messages of DefaultPriority; "emergency" is not allowed.
+	Priority string `json:"priority,omitempty"`
+}
+
+// DefaultProfileName is the name of the profile used if neither a
+// profile nor Config.DefaultProfile is specified.
+const DefaultProfileName = "default"
+
+// DefaultConfigFile returns the default path of the configuration file,
+// e.g. "~/.config/pushover/config.json" on Linux.
+func DefaultConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pushover", "config.json"), nil
+}
+
+// LoadConfig loads the configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	for name, p := range cfg.Profiles {
+		if _, err := parseDefaultPriority(p.Priority); err != nil {
+			return nil, fmt.Errorf("invalid priority in profile %q: %w", name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// Profile returns the profile with the given name. If name is empty,
+// the default profile is returned.
+func (cfg *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name == "" {
+		// An empty configuration file has no default profile, which is fine
+		name = DefaultProfileName
+		if _, found := cfg.Profiles[name]; !found {
+			return Profile{}, nil
+		}
+	}
+	p, found := cfg.Profiles[name]
+	if !found {
+		return Profile{}, fmt.Errorf("pushover: unknown profile %q", name)
+	}
+	return p, nil
+}
+
+// ProfileNames returns the names of the profiles, in sorted order.
+func (cfg *Config) ProfileNames() []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithConfigFile loads the configuration file at path. The client uses
+// the credentials and defaults of the profile specified by WithProfile,
+// or the default profile of the configuration file.
+//
+// Environment variables and other options override the profile.
+func WithConfigFile(path string) ClientOption {
+	return func(c *Client) {
+		c.configFile = path
+	}
+}
+
+// WithProfile specifies the name of the profile to use from the
+// configuration file. If no configuration file is specified with
+// WithConfigFile, DefaultConfigFile is used.
+func WithProfile(name string) ClientOption {
+	return func(c *Client) {
+		c.profile = name
+	}
+}
+
+// loadProfile returns the profile of the client, or an empty profile if
+// no configuration file or profile is specified.
+func (c *Client) loadProfile() (Profile, error) {
+	path := c.configFile
+	if path == "" && c.profile == "" {
+		return Profile{}, nil
+	}
+	if path == "" {
+		var err error
+		path, err = DefaultConfigFile()
+		if err != nil {
+			return Profile{}, fmt.Errorf("unable to find config file: %w", err)
+		}
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	return cfg.Profile(c.profile)
+}
+
+// applyDefaults returns m with the defaults of the client applied,
+// e.g. the default device and sound of the profile. The default device
+// is only applied to messages sent to the user key of the client, and
+// the default priority only to messages of DefaultPriority.
+//
+// Defaults are applied to the messages of the caller only; messages
+// generated by the package, e.g. the steps of an Escalation, are sent
+// with sendMessage.
+func (c *Client) applyDefaults(m Message) Message {
+	if len(m.Devices) == 0 && m.User == "" && c.device != "" {
+		m.Devices = strings.Split(c.device, ",")
+	}
+	if m.Sound == "" {
+		m.Sound = c.sound
+	}
+	if m.Priority == DefaultPriority {
+		m.Priority = c.priority
+	}
+	return m
+}
+
+// parseDefaultPriority parses the default priority of a profile. As
+// messages of Emergency priority require Retry and Expire, Emergency is
+// not allowed as a default.
+func parseDefaultPriority(s string) (Priority, error) {
+	p, err := ParsePriority(s)
+	if err != nil {
+		return Normal, err
+	}
+	if p == Emergency {
+		return Normal, fmt.Errorf("invalid default priority %q: emergency requires retry and expire", s)
+	}
+	return p, nil
+}
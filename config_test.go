@@ -0,0 +1,267 @@
+package pushover
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfig = `{
+	"default_profile": "personal",
+	"profiles": {
+		"personal": {
+			"app_token": "personal-token",
+			"user_key": "personal-user"
+		},
+		"work": {
+			"app_token": "work-token",
+			"user_key": "work-user",
+			"device": "phone",
+			"sound": "siren",
+			"priority": "high"
+		}
+	}
+}`
+
+func writeTestConfig(t *testing.T, config string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigProfiles(t *testing.T) {
+	for _, v := range []EnvVar{EnvAppToken, EnvUserKey} {
+		for _, name := range append([]string{v.Name}, v.Legacy...) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+
+	var form url.Values
+	ts := newTestServer(t, func(values url.Values) { form = values })
+	path := writeTestConfig(t, testConfig)
+
+	tests := []struct {
+		Name     string
+		Options  []ClientOption
+		Env      map[string]string
+		Message  Message
+		Token    string
+		User     string
+		Device   string
+		Sound    string
+		Priority string
+	}{
+		{
+			Name:    "DefaultProfile",
+			Options: []ClientOption{WithConfigFile(path)},
+			Token:   "personal-token",
+			User:    "personal-user",
+		},
+		{
+			Name:     "Profile",
+			Options:  []ClientOption{WithConfigFile(path), WithProfile("work")},
+			Message:  Message{Priority: DefaultPriority},
+			Token:    "work-token",
+			User:     "work-user",
+			Device:   "phone",
+			Sound:    "siren",
+			Priority: "1",
+		},
+		{
+			Name:    "NormalPriorityOverridesProfile",
+			Options: []ClientOption{WithConfigFile(path), WithProfile("work")},
+			Message: Message{Priority: Normal},
+			Token:   "work-token",
+			User:    "work-user",
+			Device:  "phone",
+			Sound:   "siren",
+		},
+		{
+			Name:     "MessageOverridesProfile",
+			Options:  []ClientOption{WithConfigFile(path), WithProfile("work")},
+			Message:  Message{Devices: []string{"tablet"}, Sound: "none", Priority: Low},
+			Token:    "work-token",
+			User:     "work-user",
+			Device:   "tablet",
+			Sound:    "none",
+			Priority: "-1",
+		},
+		{
+			Name:     "EnvironmentOverridesProfile",
+			Options:  []ClientOption{WithConfigFile(path), WithProfile("work")},
+			Env:      map[string]string{"APP_TOKEN": "env-token"},
+			Message:  Message{Priority: DefaultPriority},
+			Token:    "env-token",
+			User:     "work-user",
+			Device:   "phone",
+			Sound:    "siren",
+			Priority: "1",
+		},
+		{
+			Name:    "OptionsOverrideEnvironment",
+			Options: []ClientOption{WithConfigFile(path), WithAppToken("option-token")},
+			Env:     map[string]string{"APP_TOKEN": "env-token", "USER_KEY": "env-user"},
+			Token:   "option-token",
+			User:    "env-user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			for k, v := range tt.Env {
+				t.Setenv(k, v)
+			}
+			options := append([]ClientOption{WithURL(ts.URL)}, tt.Options...)
+			client, err := NewClient(options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := tt.Message
+			m.Message = "Hello"
+			if _, err := client.Messages.Send(context.Background(), m); err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range []struct{ Name, Want string }{
+				{"token", tt.Token},
+				{"user", tt.User},
+				{"device", tt.Device},
+				{"sound", tt.Sound},
+				{"priority", tt.Priority},
+			} {
+				if want, have := field.Want, form.Get(field.Name); want != have {
+					t.Fatalf("want %s=%q, have %q", field.Name, want, have)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigDefaultPriority(t *testing.T) {
+	t.Setenv(EnvPriority.Name, "")
+	os.Unsetenv(EnvPriority.Name)
+
+	var form url.Values
+	ts := newTestServer(t, func(values url.Values) { form = values })
+	config := func(priority string) string {
+		return writeTestConfig(t, `{"profiles":{"default":{"app_token":"token","user_key":"user","priority":"`+priority+`"}}}`)
+	}
+	ctx := context.Background()
+	m := Message{Message: "Backup finished", Priority: DefaultPriority}
+
+	t.Run("QuietHours", func(t *testing.T) {
+		client, err := NewClient(WithURL(ts.URL), WithConfigFile(config("normal")), WithProfile("default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Quiet hours for the whole day
+		recipients := []Recipient{{UserKey: "user", QuietHours: &QuietHours{Start: 0, End: 24 * time.Hour}}}
+		results := client.Messages.SendBatch(ctx, m, recipients, &BatchOptions{Concurrency: 1})
+		if err := results[0].Err; err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "-1", form.Get("priority"); want != have {
+			t.Fatalf("want priority=%q, have %q", want, have)
+		}
+	})
+
+	t.Run("Aggregator", func(t *testing.T) {
+		client, err := NewClient(WithURL(ts.URL), WithConfigFile(config("low")), WithProfile("default"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := NewAggregator(client.Messages, WithAggregateInterval(time.Hour))
+		if _, err := a.Send(ctx, m); err != ErrAggregated {
+			t.Fatalf("want %v, have %v", ErrAggregated, err)
+		}
+		form = nil
+		if err := a.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "-1", form.Get("priority"); want != have {
+			t.Fatalf("want priority=%q, have %q", want, have)
+		}
+	})
+}
+
+func TestConfigErrors(t *testing.T) {
+	path := writeTestConfig(t, testConfig)
+	if _, err := NewClient(WithConfigFile(path), WithProfile("unknown")); err == nil {
+		t.Fatal("want error for unknown profile, have nil")
+	}
+	if _, err := NewClient(WithConfigFile(filepath.Join(t.TempDir(), "missing.json"))); err == nil {
+		t.Fatal("want error for missing config file, have nil")
+	}
+	invalid := writeTestConfig(t, `{"profiles":{"work":{"priority":"urgent"}}}`)
+	if _, err := NewClient(WithConfigFile(invalid)); err == nil {
+		t.Fatal("want error for invalid priority, have nil")
+	}
+	emergency := writeTestConfig(t, `{"profiles":{"work":{"priority":"emergency"}}}`)
+	if _, err := NewClient(WithConfigFile(emergency)); err == nil {
+		t.Fatal("want error for emergency as default priority, have nil")
+	}
+	empty := writeTestConfig(t, `{}`)
+	if _, err := NewClient(WithConfigFile(empty)); err != nil {
+		t.Fatalf("want no error for empty config file, have %v", err)
+	}
+}
+
+func TestNamespacedEnvironment(t *testing.T) {
+	for _, v := range EnvVars() {
+		for _, name := range append([]string{v.Name}, v.Legacy...) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+
+	var form url.Values
+	ts := newTestServer(t, func(values url.Values) { form = values })
+	path := writeTestConfig(t, testConfig)
+
+	t.Setenv("APP_TOKEN", "legacy-token")
+	t.Setenv("PUSHOVER_APP_TOKEN", "namespaced-token")
+	t.Setenv("USER_KEY", "legacy-user")
+	t.Setenv("PUSHOVER_SOUND", "env-sound")
+	t.Setenv("PUSHOVER_PRIORITY", "low")
+
+	if value, source := EnvAppToken.Lookup(); value != "namespaced-token" || source != "PUSHOVER_APP_TOKEN" {
+		t.Fatalf("want namespaced token from PUSHOVER_APP_TOKEN, have %q from %q", value, source)
+	}
+	if value, source := EnvUserKey.Lookup(); value != "legacy-user" || source != "USER_KEY" {
+		t.Fatalf("want legacy user from USER_KEY, have %q from %q", value, source)
+	}
+
+	client, err := NewClient(WithURL(ts.URL), WithConfigFile(path), WithProfile("work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello", Priority: DefaultPriority}); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []struct{ Name, Want string }{
+		{"token", "namespaced-token"},
+		{"user", "legacy-user"},
+		{"device", "phone"},
+		{"sound", "env-sound"},
+		{"priority", "-1"},
+	} {
+		if want, have := field.Want, form.Get(field.Name); want != have {
+			t.Fatalf("want %s=%q, have %q", field.Name, want, have)
+		}
+	}
+
+	t.Setenv("PUSHOVER_PRIORITY", "urgent")
+	if _, err := NewClient(WithURL(ts.URL)); err == nil {
+		t.Fatal("want error for invalid priority, have nil")
+	}
+	t.Setenv("PUSHOVER_PRIORITY", "emergency")
+	if _, err := NewClient(WithURL(ts.URL)); err == nil {
+		t.Fatal("want error for emergency as default priority, have nil")
+	}
+}
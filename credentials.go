@@ -0,0 +1,265 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials are the App Token and user key of a Client.
+type Credentials struct {
+	AppToken string
+	UserKey  string
+}
+
+// CredentialsProvider provides the credentials of a Client, e.g. from
+// files mounted by an orchestrator or from a keyring. The Client asks
+// the provider for every API call, so rotated credentials are picked
+// up without recreating the Client. Providers should cache credentials
+// if getting them is expensive.
+//
+// Empty credentials returned by a provider are ignored, i.e. the App
+// Token or user key of the Client is used instead.
+//
+// Implementations must be safe for concurrent use.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// WithCredentialsProvider specifies the provider of the credentials of
+// the Client. Credentials of the provider override WithAppToken and
+// WithUserKey, as well as the environment.
+//
+// If no provider is specified and PUSHOVER_APP_TOKEN_FILE or
+// PUSHOVER_USER_KEY_FILE are set in the environment, the credentials
+// not given by WithAppToken or WithUserKey are read from these files;
+// see NewFileCredentials.
+func WithCredentialsProvider(p CredentialsProvider) ClientOption {
+	return func(c *Client) {
+		c.creds = p
+	}
+}
+
+// credentials returns the current App Token and user key of the client.
+func (c *Client) credentials(ctx context.Context) (appToken, userKey string, err error) {
+	if c.creds != nil {
+		cr, err := c.creds.Credentials(ctx)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to get credentials: %w", err)
+		}
+		c.credMu.Lock()
+		if cr.AppToken != "" {
+			c.appToken = cr.AppToken
+		}
+		if cr.UserKey != "" {
+			c.userKey = cr.UserKey
+		}
+		c.credMu.Unlock()
+	}
+	c.credMu.RLock()
+	defer c.credMu.RUnlock()
+	return c.appToken, c.userKey, nil
+}
+
+// credentialsOf returns the App Token and user key to send m with,
+// i.e. the credentials of the client, unless overridden by m.
+func (c *Client) credentialsOf(ctx context.Context, m Message) (appToken, userKey string, err error) {
+	appToken, userKey, err = c.credentials(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if m.User != "" {
+		userKey = m.User
+	}
+	if m.App != "" {
+		appToken, err = c.appTokenOf(m.App)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	if m.Token != "" {
+		appToken = m.Token
+	}
+	return appToken, userKey, nil
+}
+
+// currentAppToken returns the App Token of the client as of the last
+// API call, without asking the CredentialsProvider.
+func (c *Client) currentAppToken() string {
+	c.credMu.RLock()
+	defer c.credMu.RUnlock()
+	return c.appToken
+}
+
+// -- envCredentials --
+
+// envCredentials reads credentials from environment variables.
+type envCredentials struct {
+	appTokenVar string
+	userKeyVar  string
+}
+
+// NewEnvCredentials returns a CredentialsProvider that reads the App
+// Token and user key from the given environment variables. The
+// variables are read for every API call. Empty names are ignored.
+func NewEnvCredentials(appTokenVar, userKeyVar string) CredentialsProvider {
+	return &envCredentials{appTokenVar: appTokenVar, userKeyVar: userKeyVar}
+}
+
+// Credentials implements the CredentialsProvider interface.
+func (p *envCredentials) Credentials(context.Context) (Credentials, error) {
+	var cr Credentials
+	if p.appTokenVar != "" {
+		cr.AppToken = os.Getenv(p.appTokenVar)
+	}
+	if p.userKeyVar != "" {
+		cr.UserKey = os.Getenv(p.userKeyVar)
+	}
+	return cr, nil
+}
+
+// -- fileCredentials --
+
+// fileCredentials reads credentials from files.
+type fileCredentials struct {
+	appToken secretFile
+	userKey  secretFile
+}
+
+// secretFile is a file containing a secret. Its content is cached until
+// the file is modified.
+type secretFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	secret  string
+}
+
+// NewFileCredentials returns a CredentialsProvider that reads the App
+// Token and user key from the given files, e.g. secrets mounted by an
+// orchestrator. The files are read again when they are modified, e.g.
+// when the secrets are rotated. Empty paths are ignored.
+func NewFileCredentials(appTokenFile, userKeyFile string) CredentialsProvider {
+	return &fileCredentials{
+		appToken: secretFile{path: appTokenFile},
+		userKey:  secretFile{path: userKeyFile},
+	}
+}
+
+// Credentials implements the CredentialsProvider interface.
+func (p *fileCredentials) Credentials(context.Context) (Credentials, error) {
+	appToken, err := p.appToken.read()
+	if err != nil {
+		return Credentials{}, err
+	}
+	userKey, err := p.userKey.read()
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{AppToken: appToken, UserKey: userKey}, nil
+}
+
+// read returns the secret in the file, reading the file if it was
+// modified since it was last read.
+func (f *secretFile) read() (string, error) {
+	if f.path == "" {
+		return "", nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	if f.secret != "" && fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return f.secret, nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.secret = strings.TrimSpace(string(data))
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+	return f.secret, nil
+}
+
+// -- commandCredentials --
+
+// commandCredentials reads credentials from the output of commands.
+type commandCredentials struct {
+	appToken secretCommand
+	userKey  secretCommand
+}
+
+// secretCommand is a command that prints a secret to stdout. Its output
+// is cached for a while.
+type secretCommand struct {
+	args    []string
+	refresh time.Duration
+
+	mu      sync.Mutex
+	expires time.Time
+	secret  string
+}
+
+// NewCommandCredentials returns a CredentialsProvider that runs the
+// given commands, e.g. []string{"pass", "show", "pushover/token"}, and
+// uses their output as App Token and user key. The output of a command
+// is cached for the refresh interval; if it is zero, the command runs
+// for every API call. Empty commands are ignored.
+func NewCommandCredentials(appTokenCmd, userKeyCmd []string, refresh time.Duration) CredentialsProvider {
+	return &commandCredentials{
+		appToken: secretCommand{args: appTokenCmd, refresh: refresh},
+		userKey:  secretCommand{args: userKeyCmd, refresh: refresh},
+	}
+}
+
+// Credentials implements the CredentialsProvider interface.
+func (p *commandCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	appToken, err := p.appToken.run(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	userKey, err := p.userKey.run(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{AppToken: appToken, UserKey: userKey}, nil
+}
+
+// run returns the output of the command, running the command if the
+// cached output expired.
+func (c *secretCommand) run(ctx context.Context) (string, error) {
+	if len(c.args) == 0 {
+		return "", nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.secret != "" && time.Now().Before(c.expires) {
+		return c.secret, nil
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && stderr.Len() > 0 {
+			return "", fmt.Errorf("command %s failed: %w: %s", c.args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("command %s failed: %w", c.args[0], err)
+	}
+	c.secret = strings.TrimSpace(string(out))
+	c.expires = time.Now().Add(c.refresh)
+	return c.secret, nil
+}
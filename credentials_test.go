@@ -0,0 +1,165 @@
+package pushover
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCredentialsRotation(t *testing.T) {
+	for _, v := range []EnvVar{EnvAppToken, EnvUserKey} {
+		for _, name := range append([]string{v.Name}, v.Legacy...) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+
+	var form url.Values
+	ts := newTestServer(t, func(values url.Values) { form = values })
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "app-token")
+	userFile := filepath.Join(dir, "user-key")
+	if err := ioutil.WriteFile(tokenFile, []byte("token-1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(userFile, []byte("user-1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("APP_TOKEN_FILE", tokenFile)
+	t.Setenv("USER_KEY_FILE", userFile)
+
+	client, err := NewClient(WithURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(wantToken, wantUser string) {
+		t.Helper()
+		if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err != nil {
+			t.Fatal(err)
+		}
+		if want, have := wantToken, form.Get("token"); want != have {
+			t.Fatalf("want token %q, have %q", want, have)
+		}
+		if want, have := wantUser, form.Get("user"); want != have {
+			t.Fatalf("want user %q, have %q", want, have)
+		}
+	}
+	send("token-1", "user-1")
+
+	// Rotate the App Token
+	if err := ioutil.WriteFile(tokenFile, []byte("token-2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(tokenFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	send("token-2", "user-1")
+
+	// Missing files are reported
+	if err := os.Remove(tokenFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err == nil {
+		t.Fatal("want error for missing file, have nil")
+	}
+}
+
+func TestFileCredentialsPrecedence(t *testing.T) {
+	var form url.Values
+	ts := newTestServer(t, func(values url.Values) { form = values })
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "app-token")
+	userFile := filepath.Join(dir, "user-key")
+	if err := ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(userFile, []byte("file-user\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PUSHOVER_APP_TOKEN_FILE", tokenFile)
+	t.Setenv("PUSHOVER_USER_KEY_FILE", userFile)
+
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("explicit-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "explicit-token", form.Get("token"); want != have {
+		t.Fatalf("want token %q, have %q", want, have)
+	}
+	if want, have := "file-user", form.Get("user"); want != have {
+		t.Fatalf("want user %q, have %q", want, have)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	var form url.Values
+	ts := newTestServer(t, func(values url.Values) { form = values })
+	t.Setenv("MY_PUSHOVER_TOKEN", "env-token")
+
+	client, err := NewClient(
+		WithURL(ts.URL),
+		WithAppToken("option-token"),
+		WithUserKey("option-user"),
+		WithCredentialsProvider(NewEnvCredentials("MY_PUSHOVER_TOKEN", "MY_PUSHOVER_USER")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "env-token", form.Get("token"); want != have {
+		t.Fatalf("want token %q, have %q", want, have)
+	}
+	// Empty credentials of the provider are ignored
+	if want, have := "option-user", form.Get("user"); want != have {
+		t.Fatalf("want user %q, have %q", want, have)
+	}
+}
+
+func TestCommandCredentials(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	var form url.Values
+	ts := newTestServer(t, func(values url.Values) { form = values })
+	counter := filepath.Join(t.TempDir(), "counter")
+
+	// Every run of the command prints a new token
+	script := `echo x >> "$0"; echo "token-$(wc -l < "$0" | tr -d ' ')"`
+	creds := NewCommandCredentials([]string{"sh", "-c", script, counter}, []string{"echo", "cmd-user"}, time.Hour)
+	client, err := NewClient(WithURL(ts.URL), WithCredentialsProvider(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err != nil {
+			t.Fatal(err)
+		}
+		// The output is cached for the refresh interval
+		if want, have := "token-1", form.Get("token"); want != have {
+			t.Fatalf("want token %q, have %q", want, have)
+		}
+		if want, have := "cmd-user", form.Get("user"); want != have {
+			t.Fatalf("want user %q, have %q", want, have)
+		}
+	}
+
+	failing := NewCommandCredentials([]string{"sh", "-c", "echo denied >&2; exit 1"}, nil, 0)
+	client, err = NewClient(WithURL(ts.URL), WithCredentialsProvider(failing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err == nil {
+		t.Fatal("want error for failing command, have nil")
+	}
+}
@@ -0,0 +1,244 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDedupWindow is the default window in which a Deduplicator
+	// suppresses duplicate messages.
+	DefaultDedupWindow = time.Minute
+)
+
+// ErrSuppressed is returned by a Deduplicator when a message was not
+// sent because it is a duplicate of a message sent before.
+var ErrSuppressed = errors.New("pushover: duplicate message suppressed")
+
+// Sender sends messages, e.g. Client.Messages.
+type Sender interface {
+	Send(context.Context, Message) (*SendResponse, error)
+}
+
+// Deduplicator sends messages with a Sender, but suppresses duplicates
+// of a message within a window of time. This is useful e.g. when a
+// monitoring system fires the same alert many times per minute.
+//
+// Messages are duplicates if their fingerprints are equal. By default,
+// the fingerprint consists of the recipient, i.e. the user key, App
+// Token, application and devices, and Message.DedupKey if set, or the
+// title and the message otherwise.
+//
+// If sending a message fails, the message can be retried immediately.
+// Duplicates suppressed while the failed message was being sent are
+// carried over to the retry. If there is no retry within the window,
+// they are sent as a summary if enabled, or reported as an error
+// otherwise; see WithDedupErrorHandler.
+//
+// If summaries are enabled, a message like "Suppressed 14 duplicates"
+// is sent when the window of a message closes and duplicates of that
+// message were suppressed.
+type Deduplicator struct {
+	s           Sender
+	window      time.Duration
+	summary     bool
+	fingerprint func(Message) string
+	onError     func(error)
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+// dedupEntry is a message whose window is open.
+type dedupEntry struct {
+	m          Message
+	suppressed int
+	failed     bool // true if sending m failed
+	timer      *time.Timer
+}
+
+// DedupOption for configuring Deduplicator settings.
+type DedupOption func(*Deduplicator)
+
+// WithDedupWindow sets the window in which duplicates of a message
+// are suppressed (default: DefaultDedupWindow).
+func WithDedupWindow(window time.Duration) DedupOption {
+	return func(d *Deduplicator) {
+		d.window = window
+	}
+}
+
+// WithDedupSummary enables or disables sending a summary of the
+// suppressed duplicates when the window of a message closes
+// (default: false).
+func WithDedupSummary(enabled bool) DedupOption {
+	return func(d *Deduplicator) {
+		d.summary = enabled
+	}
+}
+
+// WithDedupFingerprint specifies the function that computes the
+// fingerprint of a message. Messages with equal fingerprints are
+// considered duplicates.
+func WithDedupFingerprint(fn func(Message) string) DedupOption {
+	return func(d *Deduplicator) {
+		d.fingerprint = fn
+	}
+}
+
+// WithDedupErrorHandler specifies a function that is called when
+// sending a summary in the background fails, or when duplicates of a
+// message that failed to send are dropped.
+func WithDedupErrorHandler(fn func(error)) DedupOption {
+	return func(d *Deduplicator) {
+		d.onError = fn
+	}
+}
+
+// NewDeduplicator creates a Deduplicator that sends messages with s,
+// e.g. Client.Messages.
+func NewDeduplicator(s Sender, options ...DedupOption) *Deduplicator {
+	d := &Deduplicator{
+		s:           s,
+		window:      DefaultDedupWindow,
+		fingerprint: defaultFingerprint,
+		entries:     make(map[string]*dedupEntry),
+	}
+	for _, o := range options {
+		o(d)
+	}
+	return d
+}
+
+// defaultFingerprint returns the recipient of m and its DedupKey, or
+// its title and message.
+func defaultFingerprint(m Message) string {
+	recipient := strings.Join([]string{m.User, m.Token, m.App, strings.Join(m.Devices, ",")}, "\x00")
+	if m.DedupKey != "" {
+		return recipient + "\x00" + m.DedupKey
+	}
+	return recipient + "\x00" + m.Title + "\x00" + m.Message
+}
+
+// Send sends m, unless it is a duplicate of a message sent within the
+// window. Duplicates are not sent and return ErrSuppressed.
+func (d *Deduplicator) Send(ctx context.Context, m Message) (*SendResponse, error) {
+	key := d.fingerprint(m)
+
+	d.mu.Lock()
+	e := &dedupEntry{m: m}
+	if prev, found := d.entries[key]; found {
+		if !prev.failed {
+			prev.suppressed++
+			d.mu.Unlock()
+			return nil, ErrSuppressed
+		}
+		// Retry of a message that failed to send
+		prev.timer.Stop()
+		e.suppressed = prev.suppressed
+	}
+	e.timer = time.AfterFunc(d.window, func() { d.expire(key, e) })
+	d.entries[key] = e
+	d.mu.Unlock()
+
+	resp, err := d.s.Send(ctx, m)
+	if err != nil {
+		// Don't suppress retries, but keep the duplicates suppressed
+		// in the meantime
+		d.mu.Lock()
+		if d.entries[key] == e {
+			if e.suppressed == 0 {
+				e.timer.Stop()
+				delete(d.entries, key)
+			} else {
+				e.failed = true
+			}
+		}
+		d.mu.Unlock()
+	}
+	return resp, err
+}
+
+// Suppressed returns the number of duplicates of m suppressed in
+// the current window.
+func (d *Deduplicator) Suppressed(m Message) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, found := d.entries[d.fingerprint(m)]; found {
+		return e.suppressed
+	}
+	return 0
+}
+
+// Flush closes all open windows, sending summaries if enabled.
+func (d *Deduplicator) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	entries := d.entries
+	d.entries = make(map[string]*dedupEntry)
+	d.mu.Unlock()
+
+	var firstErr error
+	for _, e := range entries {
+		e.timer.Stop()
+		if err := d.sendSummary(ctx, e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close closes all open windows, sending summaries if enabled, like Flush.
+func (d *Deduplicator) Close() error {
+	return d.Flush(context.Background())
+}
+
+// expire closes the window of e.
+func (d *Deduplicator) expire(key string, e *dedupEntry) {
+	d.mu.Lock()
+	if d.entries[key] != e {
+		// Already flushed
+		d.mu.Unlock()
+		return
+	}
+	delete(d.entries, key)
+	d.mu.Unlock()
+
+	if err := d.sendSummary(context.Background(), e); err != nil && d.onError != nil {
+		d.onError(err)
+	}
+}
+
+// sendSummary sends a summary of the duplicates of e, if enabled and
+// duplicates were suppressed. Summaries of messages with Emergency
+// priority are sent with High priority, so they need no acknowledgement.
+// The summary keeps the formatting of the message, e.g. HTML. If summaries
+// are disabled, dropping the duplicates of a message that failed to send
+// is reported as an error.
+func (d *Deduplicator) sendSummary(ctx context.Context, e *dedupEntry) error {
+	if e.suppressed == 0 {
+		return nil
+	}
+	if !d.summary {
+		if e.failed {
+			return fmt.Errorf("pushover: dropped %d duplicates of a message that failed to send", e.suppressed)
+		}
+		return nil
+	}
+	m := e.m
+	noun := "duplicates"
+	if e.suppressed == 1 {
+		noun = "duplicate"
+	}
+	m.Message = fmt.Sprintf("Suppressed %d %s of: %s", e.suppressed, noun, m.Message)
+	m.Attachment = ""
+	m.Timestamp = time.Time{}
+	if m.Priority == Emergency {
+		m.Priority = High
+	}
+	_, err := d.s.Send(ctx, m)
+	return err
+}
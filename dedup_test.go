@@ -0,0 +1,313 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSender records the messages it sends.
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func (s *recordingSender) Send(_ context.Context, m Message) (*SendResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, m)
+	return &SendResponse{Status: 1}, nil
+}
+
+func (s *recordingSender) sent() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message{}, s.messages...)
+}
+
+func TestDeduplicator(t *testing.T) {
+	s := &recordingSender{}
+	d := NewDeduplicator(s, WithDedupWindow(time.Hour))
+	defer d.Close()
+	ctx := context.Background()
+
+	alert := Message{Title: "Disk full", Message: "/var is at 99%"}
+	if _, err := d.Send(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := d.Send(ctx, alert); err != ErrSuppressed {
+			t.Fatalf("want %v, have %v", ErrSuppressed, err)
+		}
+	}
+	if _, err := d.Send(ctx, Message{Title: "Disk full", Message: "/home is at 99%"}); err != nil {
+		t.Fatal(err)
+	}
+	// Explicit keys override title and message
+	if _, err := d.Send(ctx, Message{Message: "CPU at 95%", DedupKey: "cpu"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Send(ctx, Message{Message: "CPU at 97%", DedupKey: "cpu"}); err != ErrSuppressed {
+		t.Fatalf("want %v, have %v", ErrSuppressed, err)
+	}
+
+	if want, have := 3, len(s.sent()); want != have {
+		t.Fatalf("want %d messages sent, have %d", want, have)
+	}
+	if want, have := 3, d.Suppressed(alert); want != have {
+		t.Fatalf("want %d suppressed, have %d", want, have)
+	}
+}
+
+func TestDeduplicatorSummary(t *testing.T) {
+	s := &recordingSender{}
+	d := NewDeduplicator(s, WithDedupWindow(50*time.Millisecond), WithDedupSummary(true))
+	defer d.Close()
+	ctx := context.Background()
+
+	alert := Message{Title: "Disk full", Message: "/var is at 99%", Priority: Emergency}
+	for i := 0; i < 15; i++ {
+		d.Send(ctx, alert)
+	}
+	d.Send(ctx, Message{Title: "No duplicates"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.sent()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("summary not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	sent := s.sent()
+	if want, have := 3, len(sent); want != have {
+		t.Fatalf("want %d messages sent, have %d", want, have)
+	}
+	summary := sent[2]
+	if want, have := "Suppressed 14 duplicates of: /var is at 99%", summary.Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+	if want, have := High, summary.Priority; want != have {
+		t.Fatalf("want Priority=%v, have %v", want, have)
+	}
+
+	// The window is closed, so the message is sent again
+	if _, err := d.Send(ctx, alert); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeduplicatorFlush(t *testing.T) {
+	s := &recordingSender{}
+	d := NewDeduplicator(s, WithDedupWindow(time.Hour), WithDedupSummary(true))
+	ctx := context.Background()
+
+	d.Send(ctx, Message{Message: "flapping"})
+	d.Send(ctx, Message{Message: "flapping"})
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sent := s.sent()
+	if want, have := 2, len(sent); want != have {
+		t.Fatalf("want %d messages sent, have %d", want, have)
+	}
+	if want, have := "Suppressed 1 duplicate of: flapping", sent[1].Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+}
+
+// failingSender fails the first n messages and then records the
+// messages it sends.
+type failingSender struct {
+	recordingSender
+	fails int
+}
+
+func (s *failingSender) Send(ctx context.Context, m Message) (*SendResponse, error) {
+	s.mu.Lock()
+	if s.fails > 0 {
+		s.fails--
+		s.mu.Unlock()
+		return nil, errors.New("network down")
+	}
+	s.mu.Unlock()
+	return s.recordingSender.Send(ctx, m)
+}
+
+func TestDeduplicatorRetryAfterFailure(t *testing.T) {
+	s := &failingSender{fails: 1}
+	d := NewDeduplicator(s, WithDedupWindow(time.Hour))
+	defer d.Close()
+	ctx := context.Background()
+
+	alert := Message{Title: "Disk full", Message: "/var is at 99%"}
+	if _, err := d.Send(ctx, alert); err == nil {
+		t.Fatal("want error, have nil")
+	}
+	if _, err := d.Send(ctx, alert); err != nil {
+		t.Fatalf("want retry to be sent, have %v", err)
+	}
+	if _, err := d.Send(ctx, alert); err != ErrSuppressed {
+		t.Fatalf("want %v, have %v", ErrSuppressed, err)
+	}
+	if want, have := 1, len(s.sent()); want != have {
+		t.Fatalf("want %d messages sent, have %d", want, have)
+	}
+}
+
+// blockingSender blocks the first message until unblock is closed,
+// and then fails it.
+type blockingSender struct {
+	failingSender
+	sending chan struct{}
+	unblock chan struct{}
+}
+
+func newBlockingSender() *blockingSender {
+	return &blockingSender{
+		failingSender: failingSender{fails: 1},
+		sending:       make(chan struct{}),
+		unblock:       make(chan struct{}),
+	}
+}
+
+func (s *blockingSender) Send(ctx context.Context, m Message) (*SendResponse, error) {
+	s.mu.Lock()
+	first := s.fails > 0
+	s.mu.Unlock()
+	if first {
+		close(s.sending)
+		<-s.unblock
+	}
+	return s.failingSender.Send(ctx, m)
+}
+
+func TestDeduplicatorDuplicatesOfFailedMessage(t *testing.T) {
+	alert := Message{Title: "Disk full", Message: "/var is at 99%"}
+
+	// sendFailedWithDuplicate sends alert, which fails after a duplicate
+	// was suppressed while sending it.
+	sendFailedWithDuplicate := func(t *testing.T, s *blockingSender, d *Deduplicator) {
+		t.Helper()
+		ctx := context.Background()
+		errc := make(chan error, 1)
+		go func() {
+			_, err := d.Send(ctx, alert)
+			errc <- err
+		}()
+		<-s.sending
+		if _, err := d.Send(ctx, alert); err != ErrSuppressed {
+			t.Fatalf("want %v, have %v", ErrSuppressed, err)
+		}
+		close(s.unblock)
+		if err := <-errc; err == nil {
+			t.Fatal("want error, have nil")
+		}
+	}
+
+	t.Run("Retry", func(t *testing.T) {
+		s := newBlockingSender()
+		d := NewDeduplicator(s, WithDedupWindow(time.Hour), WithDedupSummary(true))
+		sendFailedWithDuplicate(t, s, d)
+		if _, err := d.Send(context.Background(), alert); err != nil {
+			t.Fatalf("want retry to be sent, have %v", err)
+		}
+		if want, have := 1, d.Suppressed(alert); want != have {
+			t.Fatalf("want %d suppressed, have %d", want, have)
+		}
+	})
+
+	t.Run("Summary", func(t *testing.T) {
+		s := newBlockingSender()
+		d := NewDeduplicator(s, WithDedupWindow(time.Hour), WithDedupSummary(true))
+		sendFailedWithDuplicate(t, s, d)
+		if err := d.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		sent := s.sent()
+		if want, have := 1, len(sent); want != have {
+			t.Fatalf("want %d messages sent, have %d", want, have)
+		}
+		if want, have := "Suppressed 1 duplicate of: /var is at 99%", sent[0].Message; want != have {
+			t.Fatalf("want Message=%q, have %q", want, have)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		s := newBlockingSender()
+		d := NewDeduplicator(s, WithDedupWindow(time.Hour))
+		sendFailedWithDuplicate(t, s, d)
+		if err := d.Flush(context.Background()); err == nil {
+			t.Fatal("want error for dropped duplicates, have nil")
+		}
+		if want, have := 0, len(s.sent()); want != have {
+			t.Fatalf("want %d messages sent, have %d", want, have)
+		}
+	})
+}
+
+func TestDeduplicatorRecipients(t *testing.T) {
+	s := &recordingSender{}
+	d := NewDeduplicator(s, WithDedupWindow(time.Hour))
+	defer d.Close()
+	ctx := context.Background()
+
+	for _, m := range []Message{
+		{Message: "Deploy failed"},
+		{Message: "Deploy failed", User: "alice"},
+		{Message: "Deploy failed", User: "bob"},
+		{Message: "Deploy failed", Token: "other-token"},
+		{Message: "Deploy failed", App: "deploys"},
+		{Message: "Deploy failed", Devices: []string{"phone"}},
+		{Message: "Deploy failed", User: "alice", DedupKey: "deploy"},
+		{Message: "Deploy failed", User: "bob", DedupKey: "deploy"},
+	} {
+		if _, err := d.Send(ctx, m); err != nil {
+			t.Fatalf("want %+v to be sent, have %v", m, err)
+		}
+	}
+	if _, err := d.Send(ctx, Message{Message: "Deploy failed", User: "alice"}); err != ErrSuppressed {
+		t.Fatalf("want %v, have %v", ErrSuppressed, err)
+	}
+}
+
+func TestDeduplicatorSummaryKeepsHTML(t *testing.T) {
+	s := &recordingSender{}
+	d := NewDeduplicator(s, WithDedupWindow(time.Hour), WithDedupSummary(true))
+	ctx := context.Background()
+
+	m := Message{Message: "<b>Disk</b> full", HTML: true}
+	d.Send(ctx, m)
+	d.Send(ctx, m)
+	if err := d.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sent := s.sent()
+	if want, have := 2, len(sent); want != have {
+		t.Fatalf("want %d messages sent, have %d", want, have)
+	}
+	if !sent[1].HTML {
+		t.Fatal("want summary with HTML=true, have false")
+	}
+	if want, have := "Suppressed 1 duplicate of: <b>Disk</b> full", sent[1].Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+}
+
+func TestDeduplicatorCloseSendsSummaries(t *testing.T) {
+	s := &recordingSender{}
+	d := NewDeduplicator(s, WithDedupWindow(time.Hour), WithDedupSummary(true))
+	ctx := context.Background()
+
+	d.Send(ctx, Message{Message: "flapping"})
+	d.Send(ctx, Message{Message: "flapping"})
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(s.sent()); want != have {
+		t.Fatalf("want %d messages sent, have %d", want, have)
+	}
+}
@@ -2,13 +2,93 @@ package pushover
 
 import "os"
 
-// envString finds the first non-empty value in the given environment
-// strings. If all values are empty, defaultValue is returned.
-func envString(defaultValue string, envVars ...string) string {
-	for _, envVar := range envVars {
-		if v, ok := os.LookupEnv(envVar); ok && v != "" {
-			return v
+// EnvVar is an environment variable the Client is configured with.
+type EnvVar struct {
+	// Name of the environment variable, e.g. "PUSHOVER_APP_TOKEN".
+	Name string
+	// Legacy names of the environment variable, e.g. "APP_TOKEN". They
+	// are used if the environment variable is not set.
+	Legacy []string
+	// Secret is true if the value is a secret.
+	Secret bool
+	// Description of the environment variable.
+	Description string
+}
+
+// Lookup returns the value of the environment variable, or of its
+// first legacy name that is set, and the name it was read from. If
+// none is set, it returns empty strings.
+func (v EnvVar) Lookup() (value, source string) {
+	for _, name := range append([]string{v.Name}, v.Legacy...) {
+		if s, ok := os.LookupEnv(name); ok && s != "" {
+			return s, name
 		}
 	}
+	return "", ""
+}
+
+// String returns the value of the environment variable, or defaultValue
+// if it is not set.
+func (v EnvVar) String(defaultValue string) string {
+	if s, source := v.Lookup(); source != "" {
+		return s
+	}
 	return defaultValue
 }
+
+// Environment variables the Client is configured with.
+var (
+	EnvAppToken = EnvVar{
+		Name:        "PUSHOVER_APP_TOKEN",
+		Legacy:      []string{"APP_TOKEN"},
+		Secret:      true,
+		Description: "App Token",
+	}
+	EnvUserKey = EnvVar{
+		Name:        "PUSHOVER_USER_KEY",
+		Legacy:      []string{"USER_KEY"},
+		Secret:      true,
+		Description: "User or group key",
+	}
+	EnvAppTokenFile = EnvVar{
+		Name:        "PUSHOVER_APP_TOKEN_FILE",
+		Legacy:      []string{"APP_TOKEN_FILE"},
+		Description: "File to read the App Token from",
+	}
+	EnvUserKeyFile = EnvVar{
+		Name:        "PUSHOVER_USER_KEY_FILE",
+		Legacy:      []string{"USER_KEY_FILE"},
+		Description: "File to read the user or group key from",
+	}
+	EnvURL = EnvVar{
+		Name:        "PUSHOVER_URL",
+		Description: "Base URL of the Pushover API",
+	}
+	EnvDevice = EnvVar{
+		Name:        "PUSHOVER_DEVICE",
+		Description: "Default device",
+	}
+	EnvSound = EnvVar{
+		Name:        "PUSHOVER_SOUND",
+		Description: "Default sound",
+	}
+	EnvPriority = EnvVar{
+		Name:        "PUSHOVER_PRIORITY",
+		Description: "Default priority",
+	}
+)
+
+// EnvVars returns the environment variables the Client is configured
+// with.
+func EnvVars() []EnvVar {
+	return []EnvVar{
+		EnvAppToken,
+		EnvUserKey,
+		EnvAppTokenFile,
+		EnvUserKeyFile,
+		EnvURL,
+		EnvDevice,
+		EnvSound,
+		EnvPriority,
+	}
+}
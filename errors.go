@@ -3,46 +3,160 @@ package pushover
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
-// apiError is used when something fails.
-type apiError struct {
+var (
+	// ErrInvalidToken indicates that the App Token is invalid.
+	ErrInvalidToken = errors.New("pushover: invalid app token")
+	// ErrInvalidUser indicates that the user or group key is invalid,
+	// or the user has no active devices.
+	ErrInvalidUser = errors.New("pushover: invalid user key")
+	// ErrInvalidDevice indicates that a device name is not valid for
+	// the user.
+	ErrInvalidDevice = errors.New("pushover: invalid device")
+	// ErrMessageRequired indicates that the message is empty.
+	ErrMessageRequired = errors.New("pushover: message required")
+	// ErrMessageTooLong indicates that the message, or another field of
+	// it such as the title or URL, exceeds its maximum length.
+	ErrMessageTooLong = errors.New("pushover: message too long")
+	// ErrQuotaExceeded is returned when the application has no remaining
+	// API calls, i.e. its message limit has been reached.
+	//
+	// See https://pushover.net/api#limits for details.
+	ErrQuotaExceeded = errors.New("pushover: message quota exceeded")
+)
+
+// APIError is returned when the Pushover API responds with an error.
+//
+// Use errors.As to inspect its details, or errors.Is to compare it
+// with one of the error categories, e.g. ErrInvalidUser:
+//
+//	var e *pushover.APIError
+//	if errors.As(err, &e) {
+//		log.Printf("request %s failed: %v", e.Request, e.Errors)
+//	}
+//	if errors.Is(err, pushover.ErrInvalidUser) {
+//		...
+//	}
+type APIError struct {
 	Inner error `json:"-"`
 
-	StatusCode int      `json:"-"`
-	Status     int      `json:"status,omitempty"`
-	Request    string   `json:"request,omitempty"`
-	User       string   `json:"user,omitempty"`
-	Errors     []string `json:"errors,omitempty"`
-	Receipt    string   `json:"receipt,omitempty"`
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+	// Status is the status of the response, which is 1 on success.
+	Status int `json:"status,omitempty"`
+	// Request is the unique ID of the request.
+	Request string `json:"request,omitempty"`
+	// Token is "invalid" if the App Token is invalid.
+	Token string `json:"token,omitempty"`
+	// User is "invalid" if the user or group key is invalid.
+	User string `json:"user,omitempty"`
+	// Errors is the list of errors reported by Pushover.
+	Errors  []string `json:"errors,omitempty"`
+	Receipt string   `json:"receipt,omitempty"`
+	// Fields maps the parameters of the request reported as invalid by
+	// Pushover to the reason, e.g. "user" to "invalid".
+	Fields map[string]string `json:"-"`
+
+	// ContentType is the Content-Type header of the response.
+	ContentType string `json:"-"`
+	// Body is a snippet of the raw body of the response, if it could
+	// not be decoded, e.g. an HTML page returned by a proxy.
+	Body string `json:"-"`
+	// Limits are the API limits reported in the headers of the response,
+	// if any.
+	Limits Limits `json:"-"`
 }
 
 // Unwrap implements the errors.Wrapper interface, allowing errors.Is and
-// errors.As to work with apiErrors.
-func (e apiError) Unwrap() error {
+// errors.As to work with APIErrors.
+func (e *APIError) Unwrap() error {
 	return e.Inner
 }
 
+// Is returns true if the error belongs to the category of target,
+// e.g. ErrInvalidToken or ErrQuotaExceeded. The category is derived
+// from the HTTP status code and the parameters reported as invalid
+// by Pushover; see Fields.
+func (e *APIError) Is(target error) bool {
+	if target == ErrQuotaExceeded {
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	if e.StatusCode < 400 || e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	switch target {
+	case ErrInvalidToken:
+		return e.field("token") != ""
+	case ErrInvalidUser:
+		return e.field("user") != ""
+	case ErrInvalidDevice:
+		return e.field("device") != ""
+	case ErrMessageRequired:
+		reason := e.field("message")
+		return reason != "" && !isLengthReason(reason)
+	case ErrMessageTooLong:
+		if reason := e.field("message"); reason != "" && isLengthReason(reason) {
+			return true
+		}
+		return e.field("title") != "" || e.field("url") != "" || e.field("url_title") != ""
+	}
+	return false
+}
+
+// field returns the reason why Pushover reported the given parameter
+// of the request as invalid, or an empty string.
+func (e *APIError) field(name string) string {
+	switch name {
+	case "token":
+		if e.Token != "" {
+			return e.Token
+		}
+	case "user":
+		if e.User != "" {
+			return e.User
+		}
+	}
+	return e.Fields[name]
+}
+
+// isLengthReason returns true if reason reports that a parameter
+// exceeds its maximum length, e.g. "is too long".
+func isLengthReason(reason string) bool {
+	reason = strings.ToLower(reason)
+	return strings.Contains(reason, "long") || strings.Contains(reason, "length")
+}
+
 // Error returns a string representation of the API error.
-func (e apiError) Error() string {
+func (e *APIError) Error() string {
 	var sb strings.Builder
 	sb.WriteString("pushover: ")
 	sb.WriteString(fmt.Sprint(e.StatusCode))
 	sb.WriteString(" ")
 	sb.WriteString(http.StatusText(e.StatusCode))
-	sb.WriteString("; status=")
-	sb.WriteString(fmt.Sprint(e.Status))
+	if e.Body == "" {
+		sb.WriteString("; status=")
+		sb.WriteString(fmt.Sprint(e.Status))
+	}
 	if e.Request != "" {
 		sb.WriteString("; request=")
 		sb.WriteString(e.Request)
 	}
+	if e.Token != "" {
+		sb.WriteString("; token=")
+		sb.WriteString(e.Token)
+	}
 	if e.User != "" {
-		sb.WriteString("; status=")
+		sb.WriteString("; user=")
 		sb.WriteString(e.User)
 	}
 	if len(e.Errors) > 0 {
@@ -50,6 +164,14 @@ func (e apiError) Error() string {
 		sb.WriteString(strings.Join(e.Errors, "; "))
 		sb.WriteString("]")
 	}
+	if e.Body != "" {
+		if e.ContentType != "" {
+			sb.WriteString("; content-type=")
+			sb.WriteString(e.ContentType)
+		}
+		sb.WriteString("; body=")
+		sb.WriteString(strconv.Quote(e.Body))
+	}
 	return sb.String()
 }
 
@@ -57,14 +179,67 @@ func errorFromResponse(resp *http.Response) error {
 	if resp == nil || (resp.StatusCode >= 200 && resp.StatusCode < 300) {
 		return nil
 	}
-	var ae apiError
-	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&ae); err != nil {
-		return fmt.Errorf("pushover: request failed with status %d", resp.StatusCode)
+	ae := &APIError{
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
 	}
-	ae.StatusCode = resp.StatusCode
+	parseLimits(resp.Header, &ae.Limits)
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		ae.Inner = err
+	}
+	if err := json.Unmarshal(data, ae); err != nil || (ae.Status == 0 && ae.Request == "" && len(ae.Errors) == 0) {
+		// Not a response of Pushover, e.g. an error page of a proxy
+		ae.Body = bodySnippet(data)
+		return ae
+	}
+	ae.Fields = invalidFields(data)
 	return ae
 }
 
+// invalidFields returns the parameters reported as invalid in the
+// response body data, e.g. {"user":"invalid"}.
+func invalidFields(data []byte) map[string]string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+	var fields map[string]string
+	for name, v := range raw {
+		switch name {
+		case "status", "request", "errors", "receipt":
+			continue
+		}
+		var reason string
+		if err := json.Unmarshal(v, &reason); err != nil || reason == "" {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		fields[name] = reason
+	}
+	return fields
+}
+
+// maxBodySnippet is the maximum length of the snippet of a response
+// body kept in an APIError.
+const maxBodySnippet = 512
+
+// bodySnippet returns the first bytes of data as a string, without
+// splitting a multi-byte character.
+func bodySnippet(data []byte) string {
+	s := strings.TrimSpace(strings.ToValidUTF8(string(data), "\ufffd"))
+	if len(s) <= maxBodySnippet {
+		return s
+	}
+	i := maxBodySnippet
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i] + ellipsis
+}
+
 // IsContextErr returns true if the error is from a context that was canceled
 // or its deadline exceeded.
 func IsContextErr(err error) bool {
@@ -90,17 +265,19 @@ func IsNotFound(err interface{}) bool {
 
 // IsStatusCode returns true if the given error indicates a specific
 // HTTP status code. The err parameter can be of type *http.Response,
-// an internal error, or int (indicating the HTTP status code).
+// an error that is or wraps an *APIError, or int (indicating the HTTP
+// status code).
 func IsStatusCode(err interface{}, code int) bool {
 	switch e := err.(type) {
 	case *http.Response:
 		return e.StatusCode == code
-	case *apiError:
-		return e.StatusCode == code
-	case apiError:
-		return e.StatusCode == code
 	case int:
 		return e == code
+	case error:
+		var ae *APIError
+		if errors.As(e, &ae) {
+			return ae.StatusCode == code
+		}
 	}
 	return false
 }
@@ -0,0 +1,166 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		Name       string
+		StatusCode int
+		Body       string
+		Want       error
+		Error      string
+	}{
+		{
+			Name:       "InvalidToken",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"token":"invalid","errors":["application token is invalid"],"status":0,"request":"r1"}`,
+			Want:       ErrInvalidToken,
+			Error:      "pushover: 400 Bad Request; status=0; request=r1; token=invalid; errors=[application token is invalid]",
+		},
+		{
+			Name:       "InvalidUser",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"user":"invalid","errors":["user identifier is invalid"],"status":0,"request":"r2"}`,
+			Want:       ErrInvalidUser,
+			Error:      "pushover: 400 Bad Request; status=0; request=r2; user=invalid; errors=[user identifier is invalid]",
+		},
+		{
+			Name:       "InvalidDevice",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"device":"invalid for this user","errors":["device name is not valid for user"],"status":0,"request":"r3"}`,
+			Want:       ErrInvalidDevice,
+		},
+		{
+			Name:       "MessageRequired",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"message":"cannot be blank","errors":["message cannot be blank"],"status":0,"request":"r4"}`,
+			Want:       ErrMessageRequired,
+		},
+		{
+			Name:       "MessageTooLong",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"message":"is too long","errors":["message is too long"],"status":0,"request":"r5"}`,
+			Want:       ErrMessageTooLong,
+		},
+		{
+			Name:       "TitleTooLong",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"title":"is too long","errors":["title is too long"],"status":0,"request":"r7"}`,
+			Want:       ErrMessageTooLong,
+		},
+		{
+			Name:       "NoCategoryWithoutFields",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"errors":["user identifier is invalid"],"status":0,"request":"r8"}`,
+		},
+		{
+			Name:       "NoCategoryForServerErrors",
+			StatusCode: http.StatusInternalServerError,
+			Body:       `{"user":"invalid","errors":["user identifier is invalid"],"status":0,"request":"r9"}`,
+		},
+		{
+			Name:       "QuotaExceeded",
+			StatusCode: http.StatusTooManyRequests,
+			Body:       `{"errors":["application is over its quota"],"status":0,"request":"r6"}`,
+			Want:       ErrQuotaExceeded,
+		},
+	}
+	categories := []error{ErrInvalidToken, ErrInvalidUser, ErrInvalidDevice, ErrMessageRequired, ErrMessageTooLong, ErrQuotaExceeded}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.StatusCode)
+				fmt.Fprint(w, tt.Body)
+			}))
+			defer ts.Close()
+			client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.Messages.Send(context.Background(), Message{Message: "Hello"})
+			if err == nil {
+				t.Fatal("want error, have nil")
+			}
+			for _, category := range categories {
+				if want, have := category == tt.Want, errors.Is(err, category); want != have {
+					t.Fatalf("want errors.Is(err, %v) = %v, have %v", category, want, have)
+				}
+			}
+			var ae *APIError
+			if !errors.As(err, &ae) {
+				t.Fatalf("want *APIError, have %T", err)
+			}
+			if want, have := tt.StatusCode, ae.StatusCode; want != have {
+				t.Fatalf("want status code %d, have %d", want, have)
+			}
+			if ae.Request == "" {
+				t.Fatal("want request ID")
+			}
+			if !IsStatusCode(fmt.Errorf("wrapped: %w", err), tt.StatusCode) {
+				t.Fatalf("want IsStatusCode to match %d", tt.StatusCode)
+			}
+			if tt.Error != "" {
+				if want, have := tt.Error, err.Error(); want != have {
+					t.Fatalf("want %q, have %q", want, have)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIErrorUndecodableBody(t *testing.T) {
+	page := "<html><body><h1>502 Bad Gateway</h1>" + strings.Repeat("é", 600) + "</body></html>"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "42")
+		w.Header().Set("X-Limit-App-Reset", "1600000000")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, page)
+	}))
+	defer ts.Close()
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Messages.Send(context.Background(), Message{Message: "Hello"})
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		t.Fatalf("want *APIError, have %T", err)
+	}
+	if want, have := http.StatusBadGateway, ae.StatusCode; want != have {
+		t.Fatalf("want status code %d, have %d", want, have)
+	}
+	if want, have := "text/html", ae.ContentType; want != have {
+		t.Fatalf("want content type %q, have %q", want, have)
+	}
+	if !strings.HasPrefix(ae.Body, "<html><body><h1>502 Bad Gateway</h1>") {
+		t.Fatalf("want body snippet, have %q", ae.Body)
+	}
+	if !strings.HasSuffix(ae.Body, ellipsis) || !utf8.ValidString(ae.Body) || len(ae.Body) > maxBodySnippet+len(ellipsis) {
+		t.Fatalf("want valid, bounded body snippet, have %q", ae.Body)
+	}
+	if want, have := int64(42), ae.Limits.Remaining; want != have {
+		t.Fatalf("want remaining %d, have %d", want, have)
+	}
+	if want, have := time.Unix(1600000000, 0), ae.Limits.ResetTime; !want.Equal(have) {
+		t.Fatalf("want reset time %v, have %v", want, have)
+	}
+	if !strings.Contains(err.Error(), "content-type=text/html; body=") {
+		t.Fatalf("want content type and body in %q", err.Error())
+	}
+}
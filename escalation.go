@@ -0,0 +1,173 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultEscalationPollInterval is the default interval in which an
+	// Escalation polls the receipt of a message. Pushover asks to not
+	// poll more often than every 5 seconds.
+	DefaultEscalationPollInterval = 30 * time.Second
+)
+
+// ErrNotAcknowledged is returned when none of the steps of an
+// Escalation acknowledged the message.
+var ErrNotAcknowledged = errors.New("pushover: message not acknowledged")
+
+// EscalationStep is a step of an Escalation.
+type EscalationStep struct {
+	// Recipient to send the message to in this step, e.g. the primary
+	// on-call or a delivery group.
+	Recipient
+	// Timeout is the time to wait for the recipient to acknowledge
+	// the message before escalating to the next step. If zero, it
+	// waits until the message expires, so either Timeout or the
+	// Expire of the message of the Escalation must be set.
+	Timeout time.Duration
+}
+
+// Escalation sends a message of Emergency priority to a list of
+// recipients in order, until one of them acknowledges the message.
+//
+// In each step, the message is sent to the recipient of the step, and
+// its receipt is polled. If the message is not acknowledged within the
+// timeout of the step, the message is canceled and the next step begins.
+type Escalation struct {
+	// Message to send. Its priority is always set to Emergency.
+	// If Expire is zero, the timeout of each step is used.
+	Message Message
+	// Steps of the escalation.
+	Steps []EscalationStep
+	// PollInterval is the interval in which the receipt of a message
+	// is polled (default: DefaultEscalationPollInterval).
+	PollInterval time.Duration
+}
+
+// EscalationResult is the outcome of an Escalation.
+type EscalationResult struct {
+	// Step is the index of the step whose recipient acknowledged the
+	// message, or -1 if the message was not acknowledged.
+	Step int
+	// Receipt is the last known status of the acknowledged message.
+	Receipt *Receipt
+	// Receipts are the receipts of the messages sent in each step.
+	Receipts []string
+}
+
+// Run runs the escalation with the client c. It returns the result when
+// a recipient acknowledged the message. If none did, ErrNotAcknowledged
+// is returned together with the result.
+//
+// Run is canceled via ctx. The message of the current step is canceled
+// before Run returns then.
+func (e *Escalation) Run(ctx context.Context, c *Client) (*EscalationResult, error) {
+	interval := e.PollInterval
+	if interval <= 0 {
+		interval = DefaultEscalationPollInterval
+	}
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+	appToken, _, err := c.credentialsOf(ctx, e.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &EscalationResult{Step: -1}
+	for i, step := range e.Steps {
+		m := e.Message
+		m.Priority = Emergency
+		m.User = step.UserKey
+		if len(step.Devices) > 0 {
+			m.Devices = step.Devices
+		}
+		if m.Expire == 0 {
+			m.Expire = step.Timeout
+		}
+		resp, err := c.Messages.sendMessage(ctx, m)
+		if err != nil {
+			return res, err
+		}
+		res.Receipts = append(res.Receipts, resp.Receipt)
+
+		r, err := e.wait(ctx, c, resp.Receipt, appToken, step.Timeout, interval)
+		if err != nil {
+			cctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			c.Receipts.cancelReceipt(cctx, resp.Receipt, appToken)
+			cancel()
+			return res, err
+		}
+		if r != nil && r.Acknowledged {
+			res.Step = i
+			res.Receipt = r
+			return res, nil
+		}
+		if r == nil || !r.Expired {
+			// Timed out: Stop retrying before escalating
+			if err := c.Receipts.cancelReceipt(ctx, resp.Receipt, appToken); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, ErrNotAcknowledged
+}
+
+// validate returns an error if a step of the escalation has neither
+// a timeout nor an expiry, as Pushover requires messages of Emergency
+// priority to expire.
+func (e *Escalation) validate() error {
+	if len(e.Steps) == 0 {
+		return errors.New("pushover: escalation has no steps")
+	}
+	for i, step := range e.Steps {
+		if step.Timeout <= 0 && e.Message.Expire <= 0 {
+			return fmt.Errorf("pushover: step %d of escalation needs a Timeout, or the message an Expire", i)
+		}
+	}
+	return nil
+}
+
+// wait polls the receipt until it is acknowledged or expired, or the
+// timeout elapses. It returns the last status of the receipt, which is
+// nil if it could not be polled. Temporary errors of polling, e.g. of
+// the network, are retried on the next tick.
+func (e *Escalation) wait(ctx context.Context, c *Client, receipt, appToken string, timeout, interval time.Duration) (*Receipt, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *Receipt
+	for {
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-deadline:
+			return last, nil
+		case <-ticker.C:
+			r, err := c.Receipts.get(ctx, receipt, appToken)
+			if err != nil {
+				if ctx.Err() != nil {
+					return last, ctx.Err()
+				}
+				if isPermanentError(err) {
+					return last, err
+				}
+				// Try again on the next tick
+				continue
+			}
+			last = r
+			if r.Acknowledged || r.Expired {
+				return r, nil
+			}
+		}
+	}
+}
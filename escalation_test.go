@@ -0,0 +1,249 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// escalationTestServer emulates the messages and receipts API. Messages
+// sent to one of the acknowledging users are acknowledged immediately.
+type escalationTestServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	acknowledge map[string]bool // user keys that acknowledge
+	users       map[string]string
+	canceled    []string
+	sounds      []string
+}
+
+func newEscalationTestServer(t *testing.T, acknowledge ...string) *escalationTestServer {
+	t.Helper()
+	s := &escalationTestServer{
+		acknowledge: make(map[string]bool),
+		users:       make(map[string]string),
+	}
+	for _, user := range acknowledge {
+		s.acknowledge[user] = true
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/1/messages.json":
+			user := r.FormValue("user")
+			if r.FormValue("priority") != "2" {
+				t.Errorf("want emergency priority, have %q", r.FormValue("priority"))
+			}
+			receipt := "r-" + user
+			s.users[receipt] = user
+			s.sounds = append(s.sounds, r.FormValue("sound"))
+			fmt.Fprintf(w, `{"status":1,"request":"1","receipt":%q}`, receipt)
+		case strings.HasSuffix(r.URL.Path, "/cancel.json"):
+			receipt := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/1/receipts/"), "/cancel.json")
+			s.canceled = append(s.canceled, receipt)
+			fmt.Fprint(w, `{"status":1,"request":"1"}`)
+		case strings.HasPrefix(r.URL.Path, "/1/receipts/"):
+			receipt := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/1/receipts/"), ".json")
+			user := s.users[receipt]
+			if s.acknowledge[user] {
+				fmt.Fprintf(w, `{"status":1,"request":"1","acknowledged":1,"acknowledged_at":1600000000,"acknowledged_by":%q,"acknowledged_by_device":"phone"}`, user)
+			} else {
+				fmt.Fprint(w, `{"status":1,"request":"1","acknowledged":0}`)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *escalationTestServer) canceledReceipts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.canceled...)
+}
+
+func TestEscalation(t *testing.T) {
+	ts := newEscalationTestServer(t, "secondary")
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Escalation{
+		Message: Message{Title: "Database down", Message: "Primary database is unreachable"},
+		Steps: []EscalationStep{
+			{Recipient: Recipient{UserKey: "primary"}, Timeout: 50 * time.Millisecond},
+			{Recipient: Recipient{UserKey: "secondary"}, Timeout: time.Second},
+			{Recipient: Recipient{UserKey: "group"}, Timeout: time.Second},
+		},
+		PollInterval: 10 * time.Millisecond,
+	}
+	res, err := e.Run(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, res.Step; want != have {
+		t.Fatalf("want step %d, have %d", want, have)
+	}
+	if want, have := "secondary", res.Receipt.AcknowledgedBy; want != have {
+		t.Fatalf("want acknowledged by %q, have %q", want, have)
+	}
+	if want, have := time.Unix(1600000000, 0), res.Receipt.AcknowledgedAt; !want.Equal(have) {
+		t.Fatalf("want acknowledged at %v, have %v", want, have)
+	}
+	if want, have := []string{"r-primary", "r-secondary"}, res.Receipts; len(want) != len(have) || want[0] != have[0] || want[1] != have[1] {
+		t.Fatalf("want receipts %v, have %v", want, have)
+	}
+	if want, have := []string{"r-primary"}, ts.canceledReceipts(); len(want) != len(have) || want[0] != have[0] {
+		t.Fatalf("want canceled receipts %v, have %v", want, have)
+	}
+}
+
+func TestEscalationWithoutDefaults(t *testing.T) {
+	ts := newEscalationTestServer(t, "primary")
+	t.Setenv("PUSHOVER_SOUND", "siren")
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Escalation{
+		Message: Message{Message: "Disk full"},
+		Steps: []EscalationStep{
+			{Recipient: Recipient{UserKey: "primary"}, Timeout: time.Second},
+		},
+		PollInterval: 5 * time.Millisecond,
+	}
+	if _, err := e.Run(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if want, have := []string{""}, ts.sounds; len(want) != len(have) || want[0] != have[0] {
+		t.Fatalf("want sounds %q, have %q", want, have)
+	}
+}
+
+func TestEscalationNotAcknowledged(t *testing.T) {
+	ts := newEscalationTestServer(t)
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Escalation{
+		Message: Message{Message: "Disk full"},
+		Steps: []EscalationStep{
+			{Recipient: Recipient{UserKey: "primary"}, Timeout: 20 * time.Millisecond},
+			{Recipient: Recipient{UserKey: "secondary"}, Timeout: 20 * time.Millisecond},
+		},
+		PollInterval: 5 * time.Millisecond,
+	}
+	res, err := e.Run(context.Background(), client)
+	if err != ErrNotAcknowledged {
+		t.Fatalf("want %v, have %v", ErrNotAcknowledged, err)
+	}
+	if want, have := -1, res.Step; want != have {
+		t.Fatalf("want step %d, have %d", want, have)
+	}
+	if want, have := 2, len(ts.canceledReceipts()); want != have {
+		t.Fatalf("want %d canceled receipts, have %d", want, have)
+	}
+}
+
+func TestEscalationCanceled(t *testing.T) {
+	ts := newEscalationTestServer(t)
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Escalation{
+		Message:      Message{Message: "Disk full"},
+		Steps:        []EscalationStep{{Recipient: Recipient{UserKey: "primary"}, Timeout: time.Hour}},
+		PollInterval: 5 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := e.Run(ctx, client); err != context.DeadlineExceeded {
+		t.Fatalf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+	if want, have := []string{"r-primary"}, ts.canceledReceipts(); len(want) != len(have) || want[0] != have[0] {
+		t.Fatalf("want canceled receipts %v, have %v", want, have)
+	}
+}
+
+func TestEscalationInvalidSteps(t *testing.T) {
+	ts := newEscalationTestServer(t)
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []*Escalation{
+		{Message: Message{Message: "Disk full"}},
+		{Message: Message{Message: "Disk full"}, Steps: []EscalationStep{{Recipient: Recipient{UserKey: "primary"}}}},
+		{Message: Message{Message: "Disk full"}, Steps: []EscalationStep{
+			{Recipient: Recipient{UserKey: "primary"}, Timeout: time.Minute},
+			{Recipient: Recipient{UserKey: "secondary"}},
+		}},
+	} {
+		if _, err := e.Run(context.Background(), client); err == nil {
+			t.Fatalf("want error for %+v, have nil", e.Steps)
+		}
+	}
+	if len(ts.users) != 0 {
+		t.Fatalf("want no messages sent, have %d", len(ts.users))
+	}
+
+	// Expire of the message is sufficient
+	e := &Escalation{
+		Message:      Message{Message: "Disk full", Expire: time.Minute},
+		Steps:        []EscalationStep{{Recipient: Recipient{UserKey: "primary"}}},
+		PollInterval: 5 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := e.Run(ctx, client); err != context.DeadlineExceeded {
+		t.Fatalf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestEscalationPermanentPollError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/1/messages.json":
+			fmt.Fprint(w, `{"status":1,"request":"1","receipt":"r-primary"}`)
+		case strings.HasSuffix(r.URL.Path, "/cancel.json"):
+			fmt.Fprint(w, `{"status":1,"request":"1"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":0,"request":"1","token":"invalid","errors":["application token is invalid"]}`)
+		}
+	}))
+	defer ts.Close()
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Escalation{
+		Message:      Message{Message: "Disk full"},
+		Steps:        []EscalationStep{{Recipient: Recipient{UserKey: "primary"}, Timeout: time.Hour}},
+		PollInterval: 5 * time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = e.Run(ctx, client)
+	if !IsStatusCode(err, http.StatusBadRequest) {
+		t.Fatalf("want status code %d, have %v", http.StatusBadRequest, err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("want Run to return before the context is done")
+	}
+}
@@ -28,8 +28,8 @@ func Example() {
 func ExampleNewClient_default() {
 	// NewClient will use these environment variables to construct the configuration
 	// - PUSHOVER_URL
-	// - APP_TOKEN
-	// - USER_KEY
+	// - PUSHOVER_APP_TOKEN (or APP_TOKEN)
+	// - PUSHOVER_USER_KEY (or USER_KEY)
 	client, err := pushover.NewClient()
 	if err != nil {
 		panic(err)
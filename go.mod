@@ -1,3 +1,3 @@
 module github.com/olivere/pushover-api-go
 
-go 1.14
+go 1.21
@@ -1,20 +1,187 @@
 package pushover
 
-import "unicode/utf8"
+import (
+	"unicode"
+	"unicode/utf8"
+)
 
 const (
 	ellipsis = "…"
+	zwj      = '\u200d' // zero-width joiner
 )
 
-// cut a UTF-8 encoded string to a maximum length, and
-// optionally add a trailing sequence.
-func cut(s string, max int, trailing string) string {
-	ls := utf8.RuneCountInString(s)
-	lt := utf8.RuneCountInString(trailing)
-	if ls > max-lt {
-		r := []rune(s)[:max-lt]
-		r = append(r, []rune(trailing)...)
-		return string(r)
-	}
+// Truncate cuts a UTF-8 encoded string to a maximum number of characters,
+// and adds a trailing sequence if the string was cut. The result, including
+// the trailing sequence, has at most max characters; if the trailing
+// sequence is longer than max, it is cut as well.
+//
+// Characters are counted as Unicode code points, which is how Pushover
+// counts the length of e.g. titles and messages. Truncate never splits
+// a grapheme cluster, so e.g. emoji sequences joined with zero-width
+// joiners, flags, or letters with combining marks are either kept
+// completely or removed completely.
+func Truncate(s string, max int, trailing string) string {
+	s, _ = truncate(s, max, trailing)
+	return s
+}
+
+// TruncateBytes is like Truncate, but max is the maximum number of bytes
+// of the UTF-8 encoded result, e.g. to fit a storage or protocol limit.
+func TruncateBytes(s string, max int, trailing string) string {
+	s, _ = truncateFunc(s, max, trailing, byteLen)
 	return s
 }
+
+// truncate is like Truncate, but additionally reports whether s was cut.
+func truncate(s string, max int, trailing string) (string, bool) {
+	return truncateFunc(s, max, trailing, utf8.RuneCountInString)
+}
+
+// truncateFunc cuts s on grapheme cluster boundaries so that the result,
+// including trailing, has at most max length as measured by length.
+// It reports whether s was cut.
+func truncateFunc(s string, max int, trailing string, length func(string) int) (string, bool) {
+	if max < 0 {
+		max = 0
+	}
+	if length(s) <= max {
+		return s, false
+	}
+	if length(trailing) > max {
+		trailing, _ = truncateFunc(trailing, max, "", length)
+	}
+	budget := max - length(trailing)
+	n, i := 0, 0
+	for i < len(s) {
+		size := graphemeLen(s[i:])
+		l := length(s[i : i+size])
+		if n+l > budget {
+			break
+		}
+		n += l
+		i += size
+	}
+	return s[:i] + trailing, true
+}
+
+// byteLen returns the number of bytes of s.
+func byteLen(s string) int {
+	return len(s)
+}
+
+// graphemeLen returns the length in bytes of the first grapheme
+// cluster in s. It implements a simplified version of the rules of
+// Unicode Standard Annex #29, which is sufficient for truncating.
+//
+// See https://unicode.org/reports/tr29/ for details.
+func graphemeLen(s string) int {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return 0
+	}
+	i := size
+
+	switch {
+	case r == '\r':
+		if i < len(s) && s[i] == '\n' {
+			return i + 1
+		}
+		return i
+	case unicode.IsControl(r):
+		return i
+	case isRegionalIndicator(r):
+		// Flags consist of a pair of regional indicators
+		if next, n := utf8.DecodeRuneInString(s[i:]); isRegionalIndicator(next) {
+			i += n
+		}
+	case isHangul(r):
+		// Hangul syllables can consist of multiple jamo
+		prev := r
+		for i < len(s) {
+			next, n := utf8.DecodeRuneInString(s[i:])
+			if !hangulJoins(prev, next) {
+				break
+			}
+			prev = next
+			i += n
+		}
+	}
+
+	// Extend the cluster with combining marks, modifiers and joined emoji
+	for i < len(s) {
+		next, n := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case next == zwj:
+			i += n
+			// The zero-width joiner joins the next character, e.g. in emoji sequences
+			if i < len(s) {
+				if joined, m := utf8.DecodeRuneInString(s[i:]); !unicode.IsControl(joined) && !unicode.IsSpace(joined) {
+					i += m
+				}
+			}
+		case isGraphemeExtend(next):
+			i += n
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// isGraphemeExtend returns true if r extends the previous grapheme cluster.
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) || // emoji skin tone modifiers
+		(r >= 0xe0020 && r <= 0xe007f) || // tags, e.g. in subdivision flags
+		r == 0x200c // zero-width non-joiner
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// Hangul jamo and syllable types.
+const (
+	hangulNone = iota
+	hangulL
+	hangulV
+	hangulT
+	hangulLV
+	hangulLVT
+)
+
+func hangulType(r rune) int {
+	switch {
+	case (r >= 0x1100 && r <= 0x115f) || (r >= 0xa960 && r <= 0xa97c):
+		return hangulL
+	case (r >= 0x1160 && r <= 0x11a7) || (r >= 0xd7b0 && r <= 0xd7c6):
+		return hangulV
+	case (r >= 0x11a8 && r <= 0x11ff) || (r >= 0xd7cb && r <= 0xd7fb):
+		return hangulT
+	case r >= 0xac00 && r <= 0xd7a3:
+		if (r-0xac00)%28 == 0 {
+			return hangulLV
+		}
+		return hangulLVT
+	}
+	return hangulNone
+}
+
+func isHangul(r rune) bool {
+	return hangulType(r) != hangulNone
+}
+
+// hangulJoins returns true if the Hangul character next continues
+// the syllable ending with prev.
+func hangulJoins(prev, next rune) bool {
+	p, n := hangulType(prev), hangulType(next)
+	switch p {
+	case hangulL:
+		return n == hangulL || n == hangulV || n == hangulLV || n == hangulLVT
+	case hangulV, hangulLV:
+		return n == hangulV || n == hangulT
+	case hangulT, hangulLVT:
+		return n == hangulT
+	}
+	return false
+}
@@ -2,7 +2,7 @@ package pushover
 
 import "testing"
 
-func TestCut(t *testing.T) {
+func TestTruncate(t *testing.T) {
 	tests := []struct {
 		Input    string
 		Max      int
@@ -13,10 +13,81 @@ func TestCut(t *testing.T) {
 		{"Oliver", 5, ellipsis, "Oliv…"},
 		{"Olli", 5, ellipsis, "Olli"},
 		{"", 5, ellipsis, ""},
+		// Combining marks
+		{"Cafe\u0301s", 6, "", "Cafe\u0301s"},
+		{"Cafe\u0301s", 5, "", "Cafe\u0301"},
+		{"Cafe\u0301s", 4, "", "Caf"},
+		// Emoji ZWJ sequence (family) and skin tone modifiers
+		{"Hi \U0001F468\u200d\U0001F469\u200d\U0001F467!", 8, "", "Hi \U0001F468\u200d\U0001F469\u200d\U0001F467"},
+		{"Hi \U0001F468\u200d\U0001F469\u200d\U0001F467!", 7, "", "Hi "},
+		{"\U0001F44D\U0001F3FD\U0001F44D\U0001F3FD", 3, "", "\U0001F44D\U0001F3FD"},
+		// Flags
+		{"\U0001F1E9\U0001F1EA\U0001F1EB\U0001F1F7", 3, "", "\U0001F1E9\U0001F1EA"},
+		{"\U0001F1E9\U0001F1EA\U0001F1EB\U0001F1F7", 3, ellipsis, "\U0001F1E9\U0001F1EA…"},
+		// Hangul syllable followed by a leading jamo
+		{"\uAC01\u1100", 1, "", "\uAC01"},
+		{"\u1100\u1161\u11A8x", 3, "", "\u1100\u1161\u11A8"},
+		{"\u1100\u1161\u11A8x", 2, "", ""},
+		// CR LF
+		{"a\r\nb", 2, "", "a"},
+		// Trailing sequence longer than max
+		{"abcdef", 0, ellipsis, ""},
+		{"abcdef", 2, "...", ".."},
+		{"abcdef", -1, ellipsis, ""},
 	}
 	for _, tt := range tests {
-		if want, have := tt.Want, cut(tt.Input, tt.Max, tt.Trailing); want != have {
-			t.Fatalf("want cut(%q, %d, %q)=%q, have %q", tt.Input, tt.Max, tt.Trailing, want, have)
+		if want, have := tt.Want, Truncate(tt.Input, tt.Max, tt.Trailing); want != have {
+			t.Fatalf("want Truncate(%q, %d, %q)=%q, have %q", tt.Input, tt.Max, tt.Trailing, want, have)
+		}
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	tests := []struct {
+		Input    string
+		Max      int
+		Trailing string
+		Want     string
+	}{
+		{"Oliver", 6, "", "Oliver"},
+		{"Oliver", 5, ellipsis, "Ol…"},
+		{"Grüße", 6, "", "Grüß"},
+		{"Grüße", 5, "", "Grü"},
+		{"Cafe\u0301s", 5, "", "Caf"},
+		{"\U0001F44D\U0001F3FD\U0001F44D", 8, "", "\U0001F44D\U0001F3FD"},
+		{"\U0001F44D\U0001F3FD\U0001F44D", 7, "", ""},
+		{"abcdef", 2, ellipsis, "ab"},
+	}
+	for _, tt := range tests {
+		have := TruncateBytes(tt.Input, tt.Max, tt.Trailing)
+		if want := tt.Want; want != have {
+			t.Fatalf("want TruncateBytes(%q, %d, %q)=%q, have %q", tt.Input, tt.Max, tt.Trailing, want, have)
+		}
+		if len(have) > tt.Max && tt.Max >= 0 {
+			t.Fatalf("want TruncateBytes(%q, %d, %q) to have at most %d bytes, have %d", tt.Input, tt.Max, tt.Trailing, tt.Max, len(have))
+		}
+	}
+}
+
+func TestTruncateHTML(t *testing.T) {
+	tests := []struct {
+		Input string
+		Max   int
+		Want  string
+	}{
+		{"<b>bold</b> text", 100, "<b>bold</b> text"},
+		{"<b>bold</b> text", 13, "<b>bold</b> …"},
+		{"<b>bold</b> text", 10, "<b>bo</b>…"},
+		{"<b>bold</b> text", 8, "…"},
+		{`<font color="red">a &amp; b</font>`, 30, `<font color="red">a </font>…`},
+		{"<i>x <b>y</b> zzz</i>", 18, "<i>x <b>y</b></i>…"},
+		{"<i>x <b>y</b> zzz</i>", 19, "<i>x <b>y</b> </i>…"},
+		{"abc", 0, ""},
+	}
+	for _, tt := range tests {
+		have, _ := truncateHTML(tt.Input, tt.Max, ellipsis)
+		if want := tt.Want; want != have {
+			t.Errorf("truncateHTML(%q, %d): want %q, have %q", tt.Input, tt.Max, want, have)
 		}
 	}
 }
@@ -2,6 +2,7 @@ package pushover
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,6 +18,49 @@ type Logger interface {
 	Log(*http.Request, *http.Response, error, time.Time, time.Duration) error
 }
 
+// LoggerOption for configuring the built-in loggers.
+type LoggerOption func(*loggerOptions)
+
+// loggerOptions are the settings of the built-in loggers.
+type loggerOptions struct {
+	redact bool
+}
+
+// newLoggerOptions returns the settings of a logger from its options.
+func newLoggerOptions(options []LoggerOption) loggerOptions {
+	o := loggerOptions{redact: true}
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// WithRedaction enables or disables the redaction of secrets in log
+// output, i.e. App Tokens, user keys, Open Client secrets and the
+// contents of attachments (default: true). Disable it only for
+// debugging in a safe environment.
+func WithRedaction(enabled bool) LoggerOption {
+	return func(o *loggerOptions) {
+		o.redact = enabled
+	}
+}
+
+// attemptContextKey is the context key for the attempt of a request.
+type attemptContextKey struct{}
+
+// ContextWithAttempt returns a copy of ctx that records that requests
+// are the n-th attempt of sending, e.g. when retrying. Loggers can
+// include the attempt in their output.
+func ContextWithAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptContextKey{}, n)
+}
+
+// attemptOf returns the attempt recorded in ctx, or 0.
+func attemptOf(ctx context.Context) int {
+	n, _ := ctx.Value(attemptContextKey{}).(int)
+	return n
+}
+
 // -- nopLogger --
 
 // nopLogger doesn't log anything.
@@ -39,11 +83,13 @@ var bufPool = sync.Pool{
 // JSONLogger logs output formatted as JSON.
 type JSONLogger struct {
 	w io.Writer
+	loggerOptions
 }
 
 // NewJSONLogger creates a new JSONLogger that writes to w.
-func NewJSONLogger(w io.Writer) *JSONLogger {
-	return &JSONLogger{w: w}
+// Secrets are redacted unless disabled with WithRedaction.
+func NewJSONLogger(w io.Writer, options ...LoggerOption) *JSONLogger {
+	return &JSONLogger{w: w, loggerOptions: newLoggerOptions(options)}
 }
 
 // Log a roundtrip.
@@ -68,11 +114,21 @@ func (l *JSONLogger) Log(req *http.Request, resp *http.Response, err error, star
 	buf.WriteString(`"duration":`)
 	buf.WriteString(fmt.Sprint(duration.Nanoseconds()))
 
+	if ti := TraceInfoFromContext(req.Context()); ti != nil {
+		t := ti.Timings()
+		buf.WriteString(`,"timings":{`)
+		fmt.Fprintf(buf, `"dns":%d,"connect":%d,"tls":%d,"first_byte":%d`, t.DNS.Nanoseconds(), t.Connect.Nanoseconds(), t.TLS.Nanoseconds(), t.FirstByte.Nanoseconds())
+		buf.WriteRune('}') // end of timings
+	}
+
 	buf.WriteRune(',')
 	buf.WriteString(`"request":{`)
-	buf.WriteString(`"url":"`)
-	buf.WriteString(req.URL.String())
-	buf.WriteRune('"')
+	u := req.URL
+	if l.redact {
+		u = redactURL(u)
+	}
+	buf.WriteString(`"url":`)
+	buf.Write(quote(u.String()))
 	buf.WriteRune('}') // end of request
 
 	buf.WriteRune(',')
@@ -106,20 +162,30 @@ func (l *JSONLogger) Log(req *http.Request, resp *http.Response, err error, star
 // RawLogger logs output raw HTTP requests and responses.
 type RawLogger struct {
 	w io.Writer
+	loggerOptions
 }
 
 // NewRawLogger creates a new RawLogger that writes to w.
-func NewRawLogger(w io.Writer) *RawLogger {
-	return &RawLogger{w: w}
+// Secrets are redacted unless disabled with WithRedaction.
+func NewRawLogger(w io.Writer, options ...LoggerOption) *RawLogger {
+	return &RawLogger{w: w, loggerOptions: newLoggerOptions(options)}
 }
 
 // Log a roundtrip.
 func (l *RawLogger) Log(req *http.Request, resp *http.Response, err error, start time.Time, duration time.Duration) error {
-	out, _ := httputil.DumpRequest(req, true)
+	out, _ := httputil.DumpRequest(logRequest(req, l.redact), true)
 	fmt.Fprintln(l.w, string(out))
 
-	out, _ = httputil.DumpResponse(resp, true)
-	fmt.Fprintln(l.w, string(out))
+	if resp != nil {
+		if l.redact {
+			resp = redactResponse(resp)
+		}
+		out, _ = httputil.DumpResponse(resp, true)
+		fmt.Fprintln(l.w, string(out))
+	}
+	if err != nil {
+		fmt.Fprintf(l.w, "error: %v\n", err)
+	}
 
 	return nil
 }
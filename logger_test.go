@@ -0,0 +1,128 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	testSecretToken      = "azGDORePK8gMaC0QOYAMyEEuzJnyUi"
+	testSecretUser       = "uQiRzpo4DXghDmr9QzzfQu27cmVRsG"
+	testSecretAttachment = "PNG-attachment-contents"
+)
+
+func newLoggerTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":1,"request":"1","secret":"open-client-secret"}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestLoggerRedaction(t *testing.T) {
+	attachment := filepath.Join(t.TempDir(), "avatar.png")
+	if err := ioutil.WriteFile(attachment, []byte(testSecretAttachment), 0600); err != nil {
+		t.Fatal(err)
+	}
+	secrets := []string{testSecretToken, testSecretUser, testSecretAttachment, "open-client-secret"}
+
+	loggers := map[string]func(*bytes.Buffer, ...LoggerOption) Logger{
+		"RawLogger": func(buf *bytes.Buffer, options ...LoggerOption) Logger {
+			return NewRawLogger(buf, options...)
+		},
+		"JSONLogger": func(buf *bytes.Buffer, options ...LoggerOption) Logger {
+			return NewJSONLogger(buf, options...)
+		},
+		"SlogLogger": func(buf *bytes.Buffer, options ...LoggerOption) Logger {
+			return NewSlogLogger(slog.New(slog.NewTextHandler(buf, nil)), options...)
+		},
+	}
+	bodies := map[string]Message{
+		"urlencoded": {Message: "Hello"},
+		"multipart":  {Message: "Hello", Attachment: attachment},
+	}
+
+	for name, newLogger := range loggers {
+		for kind, m := range bodies {
+			t.Run(name+"/"+kind, func(t *testing.T) {
+				ts := newLoggerTestServer(t)
+				var buf bytes.Buffer
+				client, err := NewClient(
+					WithURL(ts.URL),
+					WithAppToken(testSecretToken),
+					WithUserKey(testSecretUser),
+					WithLogger(newLogger(&buf)),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := client.Messages.Send(context.Background(), m); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := client.Messages.Limits(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				if buf.Len() == 0 {
+					t.Fatal("want log output")
+				}
+				for _, secret := range secrets {
+					if strings.Contains(buf.String(), secret) {
+						t.Fatalf("want %q to be redacted, have:\n%s", secret, buf.String())
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestRawLoggerRedaction(t *testing.T) {
+	attachment := filepath.Join(t.TempDir(), "avatar.png")
+	if err := ioutil.WriteFile(attachment, []byte(testSecretAttachment), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ts := newLoggerTestServer(t)
+
+	for _, redact := range []bool{true, false} {
+		var buf bytes.Buffer
+		client, err := NewClient(
+			WithURL(ts.URL),
+			WithAppToken(testSecretToken),
+			WithUserKey(testSecretUser),
+			WithLogger(NewRawLogger(&buf, WithRedaction(redact))),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Messages.Send(context.Background(), Message{Message: "Hello", Attachment: attachment})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "1", resp.Request; want != have {
+			t.Fatalf("want response to be decoded after logging, have request %q", have)
+		}
+		out := buf.String()
+		if redact {
+			// The message is still logged, and the size of the attachment
+			for _, s := range []string{"Hello", redacted, redactedBytes(len(testSecretAttachment))} {
+				if !strings.Contains(out, s) {
+					t.Fatalf("want %q in output, have:\n%s", s, out)
+				}
+			}
+		} else {
+			for _, s := range []string{testSecretToken, testSecretUser, testSecretAttachment} {
+				if !strings.Contains(out, s) {
+					t.Fatalf("want %q in output without redaction, have:\n%s", s, out)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package pushover
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MarkdownToHTML converts a subset of Markdown to the subset of HTML
+// supported by Pushover.
+//
+// Supported are bold (**text** or __text__), italics (*text* or _text_),
+// links ([text](https://...)), inline code (`code`), headings and
+// ordered or unordered lists. Unsupported constructs like images, code
+// blocks or tables fall back to plain text. All text is escaped.
+//
+// If max is greater than zero, the result is truncated to at most max
+// characters including markup, without breaking any tags.
+//
+// See https://pushover.net/api#html for details.
+func MarkdownToHTML(s string, max int) string {
+	spans := parseMarkdown(s)
+	return renderSpans(spans, max, ellipsis)
+}
+
+// span is a piece of text with its surrounding markup. The text is
+// unescaped, open and close are the HTML tags around the text.
+type span struct {
+	open  string
+	text  string
+	close string
+}
+
+// length returns the number of characters of the rendered span.
+func (s span) length() int {
+	return utf8.RuneCountInString(s.open) +
+		utf8.RuneCountInString(html.EscapeString(s.text)) +
+		utf8.RuneCountInString(s.close)
+}
+
+// renderSpans renders spans as HTML. If max is greater than zero,
+// the output is truncated to max characters including the trailing
+// sequence.
+func renderSpans(spans []span, max int, trailing string) string {
+	var sb strings.Builder
+	total := 0
+	for _, s := range spans {
+		total += s.length()
+	}
+	if max <= 0 || total <= max {
+		for _, s := range spans {
+			sb.WriteString(s.open)
+			sb.WriteString(html.EscapeString(s.text))
+			sb.WriteString(s.close)
+		}
+		return sb.String()
+	}
+
+	trailing = Truncate(trailing, max, "")
+	budget := max - utf8.RuneCountInString(trailing)
+	n := 0
+	for _, s := range spans {
+		if l := s.length(); n+l <= budget {
+			sb.WriteString(s.open)
+			sb.WriteString(html.EscapeString(s.text))
+			sb.WriteString(s.close)
+			n += l
+			continue
+		}
+		// Fit as much of the text of s as possible
+		avail := budget - n - utf8.RuneCountInString(s.open) - utf8.RuneCountInString(s.close)
+		var text strings.Builder
+		for i := 0; i < len(s.text); {
+			size := graphemeLen(s.text[i:])
+			esc := html.EscapeString(s.text[i : i+size])
+			l := utf8.RuneCountInString(esc)
+			if l > avail {
+				break
+			}
+			text.WriteString(esc)
+			avail -= l
+			i += size
+		}
+		if text.Len() > 0 {
+			sb.WriteString(s.open)
+			sb.WriteString(text.String())
+			sb.WriteString(s.close)
+		}
+		break
+	}
+	sb.WriteString(trailing)
+	return sb.String()
+}
+
+// parseMarkdown parses s into spans.
+func parseMarkdown(s string) []span {
+	var (
+		spans []span
+		fence bool
+	)
+	lines := strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		if i > 0 {
+			spans = append(spans, span{text: "\n"})
+		}
+		trimmed := strings.TrimSpace(line)
+
+		// Code blocks are rendered as plain text
+		if strings.HasPrefix(trimmed, "```") {
+			fence = !fence
+			continue
+		}
+		if fence {
+			spans = append(spans, span{text: line})
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			// Headings are rendered in bold
+			heading := strings.TrimLeft(trimmed, "#")
+			if heading == "" || heading[0] != ' ' {
+				spans = append(spans, parseInline(line)...)
+				break
+			}
+			spans = append(spans, wrapSpans(parseInline(strings.TrimSpace(heading)), "<b>", "</b>")...)
+		case strings.HasPrefix(trimmed, "- "), strings.HasPrefix(trimmed, "* "), strings.HasPrefix(trimmed, "+ "):
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			spans = append(spans, span{text: indent + "• "})
+			spans = append(spans, parseInline(strings.TrimSpace(trimmed[2:]))...)
+		case strings.HasPrefix(trimmed, ">"):
+			spans = append(spans, parseInline(strings.TrimSpace(trimmed[1:]))...)
+		default:
+			if n := orderedListMarker(trimmed); n > 0 {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				spans = append(spans, span{text: indent + trimmed[:n] + " "})
+				spans = append(spans, parseInline(strings.TrimSpace(trimmed[n:]))...)
+				break
+			}
+			spans = append(spans, parseInline(line)...)
+		}
+	}
+	return spans
+}
+
+// orderedListMarker returns the length of the marker of an ordered list
+// item like "1." in s, or 0 if s is not an ordered list item.
+func orderedListMarker(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i == 0 || i+1 >= len(s) || s[i] != '.' || s[i+1] != ' ' {
+		return 0
+	}
+	return i + 1
+}
+
+// parseInline parses the inline elements of a single line of Markdown.
+func parseInline(s string) []span {
+	var (
+		spans []span
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			spans = append(spans, span{text: text.String()})
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte("\\`*_[]()#+-.!>", rest[1]) >= 0:
+			text.WriteByte(rest[1])
+			i += 2
+			continue
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				flush()
+				spans = append(spans, span{text: rest[1 : 1+end]})
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "**"), strings.HasPrefix(rest, "__"):
+			if inner, n, ok := delimited(s, i, rest[:2]); ok {
+				flush()
+				spans = append(spans, wrapSpans(parseInline(inner), "<b>", "</b>")...)
+				i += n
+				continue
+			}
+		case rest[0] == '*', rest[0] == '_':
+			if inner, n, ok := delimited(s, i, rest[:1]); ok {
+				flush()
+				spans = append(spans, wrapSpans(parseInline(inner), "<i>", "</i>")...)
+				i += n
+				continue
+			}
+		case rest[0] == '!' && len(rest) > 1 && rest[1] == '[':
+			// Images are rendered as their alternative text
+			if label, _, n, ok := link(rest[1:]); ok {
+				flush()
+				spans = append(spans, span{text: label})
+				i += n + 1
+				continue
+			}
+		case rest[0] == '[':
+			if label, href, n, ok := link(rest); ok {
+				flush()
+				if isSafeURL(href) {
+					spans = append(spans, span{
+						open:  `<a href="` + html.EscapeString(href) + `">`,
+						text:  label,
+						close: "</a>",
+					})
+				} else {
+					spans = append(spans, span{text: label})
+				}
+				i += n
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(rest)
+		text.WriteRune(r)
+		i += size
+	}
+	flush()
+	return spans
+}
+
+// delimited checks if s at position i starts an emphasis enclosed by
+// delim. It returns the enclosed text and the number of bytes consumed.
+func delimited(s string, i int, delim string) (string, int, bool) {
+	start := i + len(delim)
+	if start >= len(s) || s[start] == ' ' {
+		return "", 0, false
+	}
+	// Underscores inside words, e.g. snake_case, are no emphasis
+	if delim[0] == '_' && i > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(s[:i]); isWordRune(r) {
+			return "", 0, false
+		}
+	}
+	for j := start; j < len(s); {
+		k := strings.Index(s[j:], delim)
+		if k < 0 {
+			return "", 0, false
+		}
+		end := j + k
+		after := end + len(delim)
+		// For single delimiters, skip over double delimiters
+		if len(delim) == 1 && after < len(s) && s[after] == delim[0] {
+			j = after + 1
+			continue
+		}
+		if end == start || s[end-1] == ' ' {
+			j = after
+			continue
+		}
+		if delim[0] == '_' && after < len(s) {
+			if r, _ := utf8.DecodeRuneInString(s[after:]); isWordRune(r) {
+				j = after
+				continue
+			}
+		}
+		return s[start:end], after - i, true
+	}
+	return "", 0, false
+}
+
+// link parses a Markdown link of the form [label](href) at the start of s.
+// It returns the label, the href and the number of bytes consumed.
+func link(s string) (string, string, int, bool) {
+	closeLabel := strings.Index(s, "](")
+	if closeLabel < 0 {
+		return "", "", 0, false
+	}
+	closeHref := strings.IndexByte(s[closeLabel+2:], ')')
+	if closeHref < 0 {
+		return "", "", 0, false
+	}
+	label := s[1:closeLabel]
+	href := strings.TrimSpace(s[closeLabel+2 : closeLabel+2+closeHref])
+	return label, href, closeLabel + 2 + closeHref + 1, true
+}
+
+// isSafeURL returns true if href can be used as a link in a message.
+func isSafeURL(href string) bool {
+	lower := strings.ToLower(href)
+	return strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(lower, "http://") ||
+		strings.HasPrefix(lower, "mailto:")
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wrapSpans wraps each of spans in the given tags.
+func wrapSpans(spans []span, open, close string) []span {
+	for i := range spans {
+		spans[i].open = open + spans[i].open
+		spans[i].close = spans[i].close + close
+	}
+	return spans
+}
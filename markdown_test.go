@@ -0,0 +1,71 @@
+package pushover
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  string
+	}{
+		{"", ""},
+		{"Hello world", "Hello world"},
+		{"a < b & c", "a &lt; b &amp; c"},
+		{"**bold** and __bold__", "<b>bold</b> and <b>bold</b>"},
+		{"*italic* and _italic_", "<i>italic</i> and <i>italic</i>"},
+		{"**bold _italic_**", "<b>bold </b><b><i>italic</i></b>"},
+		{"snake_case_name", "snake_case_name"},
+		{"2 * 3 * 4", "2 * 3 * 4"},
+		{"`**not bold**`", "**not bold**"},
+		{`\*literal\*`, "*literal*"},
+		{"[Docs](https://example.com/?a=1&b=2)", `<a href="https://example.com/?a=1&amp;b=2">Docs</a>`},
+		{"[Evil](javascript:void)", "Evil"},
+		{"![Logo](https://example.com/logo.png)", "Logo"},
+		{"# Alert", "<b>Alert</b>"},
+		{"#hashtag", "#hashtag"},
+		{"- one\n* two\n  + three", "• one\n• two\n  • three"},
+		{"1. one\n2. **two**", "1. one\n2. <b>two</b>"},
+		{"> quoted", "quoted"},
+		{"```\n**code**\n```", "\n**code**\n"},
+		{"<script>", "&lt;script&gt;"},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Want, MarkdownToHTML(tt.Input, 0); want != have {
+			t.Errorf("MarkdownToHTML(%q): want %q, have %q", tt.Input, want, have)
+		}
+	}
+}
+
+func TestMarkdownToHTMLTruncate(t *testing.T) {
+	tests := []struct {
+		Input string
+		Max   int
+		Want  string
+	}{
+		{"**bold** text", 100, "<b>bold</b> text"},
+		{"**bold** text", 13, "<b>bold</b> …"},
+		{"**bold** text", 10, "<b>bo</b>…"},
+		{"**bold** text", 8, "…"},
+		{"a < b", 5, "a …"},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Want, MarkdownToHTML(tt.Input, tt.Max); want != have {
+			t.Errorf("MarkdownToHTML(%q, %d): want %q, have %q", tt.Input, tt.Max, want, have)
+		}
+	}
+
+	long := strings.Repeat("**bold** and [a link](https://example.com) ", 100)
+	out := MarkdownToHTML(long, 1024)
+	if n := utf8.RuneCountInString(out); n > 1024 {
+		t.Fatalf("want at most 1024 characters, have %d", n)
+	}
+	if want, have := strings.Count(out, "<b>"), strings.Count(out, "</b>"); want != have {
+		t.Fatalf("want balanced <b> tags, have %d open and %d closed", want, have)
+	}
+	if want, have := strings.Count(out, "<a "), strings.Count(out, "</a>"); want != have {
+		t.Fatalf("want balanced <a> tags, have %d open and %d closed", want, have)
+	}
+}
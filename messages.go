@@ -29,15 +29,133 @@ const (
 	// Emergency will bypass the user's quiet hours and will require
 	// a confirmation by the user.
 	Emergency Priority = 2
+
+	// DefaultPriority is replaced with the default priority of the client
+	// when the message is sent, e.g. the priority of its profile, or
+	// Normal if it has none; see WithProfile. SendBatch and an Aggregator
+	// sending with Client.Messages resolve it before applying quiet hours
+	// or aggregating. QuietHours.Apply leaves it as it is.
+	DefaultPriority Priority = -100
+)
+
+// QuietAction specifies how QuietHours handle messages of Normal priority.
+type QuietAction int
+
+const (
+	// QuietDowngrade sends messages of Normal priority during quiet hours
+	// with Low priority, i.e. as quiet notifications.
+	QuietDowngrade QuietAction = iota
+	// QuietDefer defers messages of Normal priority until the quiet
+	// hours end.
+	QuietDefer
 )
 
+// QuietHours is a daily window of time in the time zone of a recipient,
+// e.g. from 22:00 to 07:00, in which the recipient should not be
+// disturbed by messages of Normal priority. Messages of High and
+// Emergency priority always go through.
+//
+// Quiet hours are applied on the client side, unlike the quiet hours
+// configured on the devices of a user.
+type QuietHours struct {
+	// Location is the time zone of the recipient (default: time.Local).
+	Location *time.Location
+	// Start of the quiet hours as the time since midnight, e.g. 22*time.Hour.
+	Start time.Duration
+	// End of the quiet hours as the time since midnight, e.g. 7*time.Hour.
+	// If End is before Start, the quiet hours span midnight.
+	End time.Duration
+	// Action specifies how to handle messages of Normal priority during
+	// the quiet hours (default: QuietDowngrade).
+	Action QuietAction
+}
+
+// Contains returns true if t is within the quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	offset := q.offset(t)
+	switch {
+	case q.Start < q.End:
+		return offset >= q.Start && offset < q.End
+	case q.Start > q.End:
+		return offset >= q.Start || offset < q.End
+	}
+	return false
+}
+
+// Apply applies the quiet hours to m, to be sent at time now. It returns
+// the message to send, with Normal priority downgraded to Low if
+// required, and the time at which to send it, which is after now if the
+// message needs to be deferred until the quiet hours end.
+func (q QuietHours) Apply(m Message, now time.Time) (Message, time.Time) {
+	if m.Priority != Normal || !q.Contains(now) {
+		return m, now
+	}
+	switch q.Action {
+	case QuietDefer:
+		return m, q.end(now)
+	default:
+		m.Priority = Low
+		return m, now
+	}
+}
+
+// end returns the end of the quiet hours that contain t.
+func (q QuietHours) end(t time.Time) time.Time {
+	t = t.In(q.location())
+	y, mo, d := t.Date()
+	if q.Start > q.End && q.offset(t) >= q.Start {
+		d++ // ends on the next day
+	}
+	return time.Date(y, mo, d,
+		int(q.End/time.Hour), int(q.End%time.Hour/time.Minute), int(q.End%time.Minute/time.Second),
+		0, q.location())
+}
+
+// offset returns the time since midnight of t in the time zone of q.
+func (q QuietHours) offset(t time.Time) time.Duration {
+	t = t.In(q.location())
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+}
+
+func (q QuietHours) location() *time.Location {
+	if q.Location == nil {
+		return time.Local
+	}
+	return q.Location
+}
+
+// ParsePriority parses a priority by its name (lowest, low, normal, high,
+// or emergency) or its numeric value (-2 to 2). An empty string is
+// parsed as Normal priority.
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "normal", "0":
+		return Normal, nil
+	case "lowest", "-2":
+		return Lowest, nil
+	case "low", "-1":
+		return Low, nil
+	case "high", "1":
+		return High, nil
+	case "emergency", "2":
+		return Emergency, nil
+	}
+	return Normal, fmt.Errorf("invalid priority %q", s)
+}
+
 // Message to send.
 type Message struct {
 	// Message to send. You can use up to 1024 4-byte UTF-8 characters here.
-	// Longer messages are automatically truncated.
+	// Longer messages are automatically truncated, see Truncate. HTML
+	// messages are truncated without breaking up tags or entities.
 	Message string
 	// HTML enables or disables HTML formatting in the Message (default: false).
 	HTML bool
+	// Markdown, when enabled, converts a subset of Markdown in the Message
+	// to HTML before sending (default: false). See MarkdownToHTML for details.
+	Markdown bool
 	// Monospace, when enabled, formats the message with a monospace font (default: false).
 	Monospace bool
 	// Title of the message (optional). If missing, it will use
@@ -58,7 +176,8 @@ type Message struct {
 	// maximum length of 100 4-byte UTF-8 characters and will
 	// be automatically truncated.
 	URLTitle string
-	// Priority of the message (optional). By default, we use a Normal priority.
+	// Priority of the message (optional). By default, we use a Normal
+	// priority. Use DefaultPriority for the default priority of the client.
 	//
 	// If the Priority is Emergency, you must supply Retry and Expire as
 	// described https://pushover.net/api#priority.
@@ -85,12 +204,36 @@ type Message struct {
 
 	// CallbackURL, if set, specifies a publically accessible URL that is invoked
 	// when the user acknowledged the message, e.g. when Priority is Emergency.
+	// Use CallbackHandler to receive the callbacks.
 	CallbackURL string
 
 	// Tags can be used instead of receipts when the server is unable to process
 	// receipts. Tags will be stored on Pushover servers and can be used to e.g.
 	// cancel receipts by tag.
 	Tags []string
+
+	// TTL specifies the time after which the message will be deleted
+	// from the user's devices (optional). It must be at least one second
+	// and is not supported for messages with Emergency priority.
+	//
+	// For details, see https://pushover.net/api#ttl.
+	TTL time.Duration
+
+	// User is the user or group key to send the message to (optional).
+	// If missing, the user key of the Client is used.
+	User string
+	// Token is the App Token (or API token) to send the message with
+	// (optional). If missing, the App Token of the Client is used.
+	Token string
+	// App is the name of an application registered with WithApp
+	// (optional). The message is sent with the App Token of that
+	// application, unless Token is set.
+	App string
+
+	// DedupKey identifies duplicates of the message when sent with
+	// a Deduplicator (optional). If missing, the title and message
+	// are used to identify duplicates. It is not sent to Pushover.
+	DedupKey string
 }
 
 type messagesAPI struct {
@@ -102,25 +245,100 @@ type SendResponse struct {
 	Receipt string `json:"receipt,omitempty"`
 	Status  int    `json:"status,omitempty"`
 	Request string `json:"request,omitempty"`
+
+	// Truncated contains the names of the fields that were truncated
+	// before sending because they exceeded the limits of Pushover,
+	// e.g. "message" or "title".
+	Truncated []string `json:"-"`
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+	// Limits are the API limits reported in the headers of the response.
+	Limits Limits `json:"-"`
+	// Header contains the headers of the response.
+	Header http.Header `json:"-"`
 }
 
-// Send a message.
+// Send a message. The defaults of the profile of the client, e.g. its
+// default device and sound, are applied to m; see WithProfile.
 func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, error) {
+	return api.sendMessage(ctx, api.c.applyDefaults(m))
+}
+
+// defaultPriority returns the default priority of the client, which
+// replaces DefaultPriority.
+func (api *messagesAPI) defaultPriority() Priority {
+	return api.c.priority
+}
+
+// sendMessage sends m without applying the defaults of the client,
+// e.g. for messages generated by the package itself.
+func (api *messagesAPI) sendMessage(ctx context.Context, m Message) (*SendResponse, error) {
+	ti := &TraceInfo{
+		Endpoint:       "messages",
+		Priority:       m.Priority,
+		AttachmentSize: attachmentSize(m),
+	}
+	ctx, end := api.c.startSpan(ctx, "pushover.messages.send", ti)
+	resp, err := api.send(ctx, m)
+	if resp != nil {
+		ti.Request = resp.Request
+	}
+	api.c.metrics.ObserveMessage(m.Priority, resultOf(err))
+	end(err)
+	return resp, err
+}
+
+func (api *messagesAPI) send(ctx context.Context, m Message) (*SendResponse, error) {
+	if err := validateTTL(m); err != nil {
+		return nil, err
+	}
+	appToken, userKey, err := api.c.credentialsOf(ctx, m)
+	if err != nil {
+		return nil, err
+	}
 	var (
 		body        io.Reader
 		contentType string
 	)
+	if m.Markdown {
+		m.Message = MarkdownToHTML(m.Message, 1024)
+		m.HTML = true
+		m.Monospace = false
+	}
+	var truncated []string
+	fields := []struct {
+		name     string
+		value    *string
+		max      int
+		trailing string
+	}{
+		{"message", &m.Message, 1024, ellipsis},
+		{"title", &m.Title, 250, ellipsis},
+		{"url", &m.URL, 512, ""},
+		{"url_title", &m.URLTitle, 100, ellipsis},
+	}
+	for _, f := range fields {
+		cut := truncate
+		if f.name == "message" && m.HTML {
+			cut = truncateHTML
+		}
+		var ok bool
+		if *f.value, ok = cut(*f.value, f.max, f.trailing); ok {
+			truncated = append(truncated, f.name)
+		}
+	}
 	if m.Attachment != "" {
 		buf := &bytes.Buffer{}
 		w := multipart.NewWriter(buf)
 		contentType = w.FormDataContentType()
-		if err := w.WriteField("token", api.c.appToken); err != nil {
+		if err := w.WriteField("token", appToken); err != nil {
 			return nil, fmt.Errorf("unable to write token field: %w", err)
 		}
-		if err := w.WriteField("user", api.c.userKey); err != nil {
+		if err := w.WriteField("user", userKey); err != nil {
 			return nil, fmt.Errorf("unable to write user field: %w", err)
 		}
-		if err := w.WriteField("message", cut(m.Message, 1024, ellipsis)); err != nil {
+		if err := w.WriteField("message", m.Message); err != nil {
 			return nil, fmt.Errorf("unable to write message field: %w", err)
 		}
 		if v := m.HTML; v {
@@ -134,7 +352,7 @@ func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, err
 			}
 		}
 		if v := m.Title; v != "" {
-			if err := w.WriteField("title", cut(v, 250, ellipsis)); err != nil {
+			if err := w.WriteField("title", v); err != nil {
 				return nil, fmt.Errorf("unable to write title field: %w", err)
 			}
 		}
@@ -144,12 +362,12 @@ func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, err
 			}
 		}
 		if v := m.URL; v != "" {
-			if err := w.WriteField("url", cut(v, 512, "")); err != nil {
+			if err := w.WriteField("url", v); err != nil {
 				return nil, fmt.Errorf("unable to write url field: %w", err)
 			}
 		}
 		if v := m.URLTitle; v != "" {
-			if err := w.WriteField("url_title", cut(v, 100, ellipsis)); err != nil {
+			if err := w.WriteField("url_title", v); err != nil {
 				return nil, fmt.Errorf("unable to write url_title field: %w", err)
 			}
 		}
@@ -195,6 +413,11 @@ func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, err
 				return nil, fmt.Errorf("unable to write monospace field: %w", err)
 			}
 		}
+		if ttl := ttlSeconds(m); ttl > 0 {
+			if err := w.WriteField("ttl", fmt.Sprint(ttl)); err != nil {
+				return nil, fmt.Errorf("unable to write ttl field: %w", err)
+			}
+		}
 		// Attachment
 		{
 			contents, err := ioutil.ReadFile(m.Attachment)
@@ -213,9 +436,9 @@ func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, err
 		body = buf
 	} else {
 		values := url.Values{}
-		values.Add("token", api.c.appToken)
-		values.Add("user", api.c.userKey)
-		values.Add("message", cut(m.Message, 1024, ellipsis))
+		values.Add("token", appToken)
+		values.Add("user", userKey)
+		values.Add("message", m.Message)
 		if v := m.HTML; v {
 			values.Add("html", "1")
 		}
@@ -223,16 +446,16 @@ func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, err
 			values.Add("monospace", "1")
 		}
 		if v := m.Title; v != "" {
-			values.Add("title", cut(v, 250, ellipsis))
+			values.Add("title", v)
 		}
 		if v := m.Devices; len(v) > 0 {
 			values.Add("device", strings.Join(v, ","))
 		}
 		if v := m.URL; v != "" {
-			values.Add("url", cut(v, 512, ""))
+			values.Add("url", v)
 		}
 		if v := m.URLTitle; v != "" {
-			values.Add("url_title", cut(v, 100, ellipsis))
+			values.Add("url_title", v)
 		}
 		if v := m.Priority; v != Normal {
 			values.Add("priority", fmt.Sprint(v))
@@ -262,9 +485,13 @@ func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, err
 		if v := m.Tags; len(v) > 0 {
 			values.Add("tags", strings.Join(v, ","))
 		}
+		if ttl := ttlSeconds(m); ttl > 0 {
+			values.Add("ttl", fmt.Sprint(ttl))
+		}
 		body = strings.NewReader(values.Encode())
 		contentType = "application/x-www-form-urlencoded"
 	}
+	ctx = context.WithValue(ctx, appTokenContextKey{}, appToken)
 	req, err := http.NewRequestWithContext(ctx, "POST", "/1/messages.json", body)
 	if err != nil {
 		return nil, err
@@ -279,9 +506,32 @@ func (api *messagesAPI) Send(ctx context.Context, m Message) (*SendResponse, err
 	if err := parseResponse(resp, &ret); err != nil {
 		return nil, err
 	}
+	ret.Truncated = truncated
+	ret.StatusCode = resp.StatusCode
+	ret.Header = resp.Header
+	parseLimits(resp.Header, &ret.Limits)
 	return &ret, nil
 }
 
+// validateTTL returns an error if the TTL of m is invalid, i.e. if it
+// is negative or less than one second, or if m has Emergency priority.
+func validateTTL(m Message) error {
+	switch {
+	case m.TTL == 0:
+		return nil
+	case m.TTL < time.Second:
+		return fmt.Errorf("pushover: invalid TTL %v: must be at least one second", m.TTL)
+	case m.Priority == Emergency:
+		return fmt.Errorf("pushover: invalid TTL %v: not supported for messages of Emergency priority", m.TTL)
+	}
+	return nil
+}
+
+// ttlSeconds returns the TTL of m in seconds, or 0 if m has no TTL.
+func ttlSeconds(m Message) int64 {
+	return int64(m.TTL / time.Second)
+}
+
 // Limits represents the API limits of the current application,
 // i.e the current call limit, the number of remaining calls,
 // and the time when the limits are reset.
@@ -295,13 +545,20 @@ type Limits struct {
 }
 
 // Limits returns the API limits of the current application.
-func (api *messagesAPI) Limits(ctx context.Context) (*Limits, error) {
+func (api *messagesAPI) Limits(ctx context.Context) (_ *Limits, err error) {
+	ctx, end := api.c.startSpan(ctx, "pushover.messages.limits", &TraceInfo{Endpoint: "limits"})
+	defer func() { end(err) }()
+
 	u, err := url.Parse("/1/apps/limits.json")
 	if err != nil {
 		return nil, err
 	}
+	appToken, _, err := api.c.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
 	values := url.Values{}
-	values.Add("token", api.c.appToken)
+	values.Add("token", appToken)
 	u.RawQuery = values.Encode()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), http.NoBody)
@@ -0,0 +1,308 @@
+package pushover
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// newTestServer returns a test server that passes the parsed form of
+// each request to fn and responds with a successful send response.
+func newTestServer(t *testing.T, fn func(url.Values)) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+			t.Errorf("unable to parse form: %v", err)
+		}
+		if fn != nil {
+			fn(r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":1,"request":"647d2300-702c-4b38-8b2f-d56326ae460b"}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestMessagesSendTTL(t *testing.T) {
+	tests := []struct {
+		Name       string
+		Priority   Priority
+		TTL        time.Duration
+		Attachment bool
+		Want       string
+	}{
+		{"No TTL", Normal, 0, false, ""},
+		{"TTL", Normal, 90 * time.Second, false, "90"},
+		{"TTL with attachment", Low, time.Minute, true, "60"},
+		{"TTL rounded down to seconds", Normal, 1500 * time.Millisecond, false, "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			var form url.Values
+			ts := newTestServer(t, func(v url.Values) { form = v })
+			client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := Message{Message: "Build running", Priority: tt.Priority, TTL: tt.TTL}
+			if tt.Attachment {
+				m.Attachment = "LICENSE"
+			}
+			if _, err := client.Messages.Send(context.Background(), m); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if want, have := tt.Want, form.Get("ttl"); want != have {
+				t.Fatalf("want ttl=%q, have %q", want, have)
+			}
+		})
+	}
+}
+
+func TestMessagesSendInvalidTTL(t *testing.T) {
+	tests := []struct {
+		Name       string
+		Priority   Priority
+		TTL        time.Duration
+		Attachment bool
+	}{
+		{"Less than a second", Normal, 500 * time.Millisecond, false},
+		{"Negative", Normal, -time.Minute, false},
+		{"Emergency", Emergency, time.Minute, false},
+		{"Emergency with attachment", Emergency, time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			var called bool
+			ts := newTestServer(t, func(url.Values) { called = true })
+			client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := Message{Message: "Build running", Priority: tt.Priority, TTL: tt.TTL}
+			if tt.Attachment {
+				m.Attachment = "LICENSE"
+			}
+			if _, err := client.Messages.Send(context.Background(), m); err == nil {
+				t.Fatal("want error, have nil")
+			}
+			if called {
+				t.Fatal("want message not to be sent")
+			}
+		})
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  Priority
+		Err   bool
+	}{
+		{"", Normal, false},
+		{"lowest", Lowest, false},
+		{"Low", Low, false},
+		{"normal", Normal, false},
+		{"high", High, false},
+		{" emergency ", Emergency, false},
+		{"-2", Lowest, false},
+		{"2", Emergency, false},
+		{"urgent", Normal, true},
+	}
+	for _, tt := range tests {
+		have, err := ParsePriority(tt.Input)
+		if tt.Err != (err != nil) {
+			t.Fatalf("ParsePriority(%q): want error=%v, have %v", tt.Input, tt.Err, err)
+		}
+		if want := tt.Want; want != have {
+			t.Fatalf("ParsePriority(%q): want %v, have %v", tt.Input, want, have)
+		}
+	}
+}
+
+func TestMessagesSendTruncated(t *testing.T) {
+	var form url.Values
+	ts := newTestServer(t, func(v url.Values) { form = v })
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Message{
+		Title:   strings.Repeat("\U0001F1E9\U0001F1EA", 126),
+		Message: "Short message",
+	}
+	resp, err := client.Messages.Send(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"title"}, resp.Truncated; len(want) != len(have) || want[0] != have[0] {
+		t.Fatalf("want Truncated=%v, have %v", want, have)
+	}
+	if want, have := strings.Repeat("\U0001F1E9\U0001F1EA", 124)+"…", form.Get("title"); want != have {
+		t.Fatalf("want title=%q, have %q", want, have)
+	}
+
+	resp, err = client.Messages.Send(context.Background(), Message{Message: "Short message"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Truncated) != 0 {
+		t.Fatalf("want no truncated fields, have %v", resp.Truncated)
+	}
+}
+
+func TestMessagesSendTruncatedHTML(t *testing.T) {
+	var form url.Values
+	ts := newTestServer(t, func(v url.Values) { form = v })
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Message{
+		Message: strings.Repeat(`<b>Tom &amp; Jerry</b> <a href="https://example.com">link</a> `, 30),
+		HTML:    true,
+	}
+	resp, err := client.Messages.Send(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"message"}, resp.Truncated; len(want) != len(have) || want[0] != have[0] {
+		t.Fatalf("want Truncated=%v, have %v", want, have)
+	}
+	msg := form.Get("message")
+	if n := utf8.RuneCountInString(msg); n > 1024 {
+		t.Fatalf("want at most 1024 characters, have %d", n)
+	}
+	if want, have := strings.Count(msg, "<b>"), strings.Count(msg, "</b>"); want != have {
+		t.Fatalf("want balanced <b> tags, have %d open and %d closed", want, have)
+	}
+	if want, have := strings.Count(msg, "<a "), strings.Count(msg, "</a>"); want != have {
+		t.Fatalf("want balanced <a> tags, have %d open and %d closed", want, have)
+	}
+	if i := strings.LastIndexByte(msg, '&'); i >= 0 && !strings.HasPrefix(msg[i:], "&amp;") {
+		t.Fatalf("want entities not to be broken up, have %q", msg[i:])
+	}
+}
+
+func TestMessagesSendOverrides(t *testing.T) {
+	var form url.Values
+	ts := newTestServer(t, func(v url.Values) { form = v })
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "token", form.Get("token"); want != have {
+		t.Fatalf("want token=%q, have %q", want, have)
+	}
+	if want, have := "user", form.Get("user"); want != have {
+		t.Fatalf("want user=%q, have %q", want, have)
+	}
+
+	m := Message{Message: "Hi", User: "other-user", Token: "other-token"}
+	if _, err := client.Messages.Send(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "other-token", form.Get("token"); want != have {
+		t.Fatalf("want token=%q, have %q", want, have)
+	}
+	if want, have := "other-user", form.Get("user"); want != have {
+		t.Fatalf("want user=%q, have %q", want, have)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	q := QuietHours{Location: berlin, Start: 22 * time.Hour, End: 7 * time.Hour}
+	at := func(hour, min int) time.Time {
+		return time.Date(2020, 9, 1, hour, min, 0, 0, berlin).UTC()
+	}
+
+	tests := []struct {
+		Time     time.Time
+		Contains bool
+	}{
+		{at(21, 59), false},
+		{at(22, 0), true},
+		{at(23, 30), true},
+		{at(3, 0), true},
+		{at(7, 0), false},
+		{at(12, 0), false},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Contains, q.Contains(tt.Time); want != have {
+			t.Errorf("Contains(%v): want %v, have %v", tt.Time.In(berlin), want, have)
+		}
+	}
+
+	// Normal priority is downgraded
+	m, sendAt := q.Apply(Message{Priority: Normal}, at(23, 0))
+	if want, have := Low, m.Priority; want != have {
+		t.Fatalf("want Priority=%v, have %v", want, have)
+	}
+	if want, have := at(23, 0), sendAt; !want.Equal(have) {
+		t.Fatalf("want send at %v, have %v", want, have)
+	}
+
+	// High priority always goes through
+	m, _ = q.Apply(Message{Priority: High}, at(23, 0))
+	if want, have := High, m.Priority; want != have {
+		t.Fatalf("want Priority=%v, have %v", want, have)
+	}
+
+	// Deferred until the end of the quiet hours
+	q.Action = QuietDefer
+	m, sendAt = q.Apply(Message{Priority: Normal}, at(23, 0))
+	if want, have := Normal, m.Priority; want != have {
+		t.Fatalf("want Priority=%v, have %v", want, have)
+	}
+	if want, have := time.Date(2020, 9, 2, 7, 0, 0, 0, berlin), sendAt; !want.Equal(have) {
+		t.Fatalf("want send at %v, have %v", want, have)
+	}
+	_, sendAt = q.Apply(Message{Priority: Normal}, at(5, 0))
+	if want, have := time.Date(2020, 9, 1, 7, 0, 0, 0, berlin), sendAt; !want.Equal(have) {
+		t.Fatalf("want send at %v, have %v", want, have)
+	}
+}
+
+func TestMessagesSendResponseMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "9999")
+		w.Header().Set("X-Limit-App-Reset", "1600000000")
+		w.Write([]byte(`{"status":1,"request":"647d2300-702c-4b38-8b2f-d56326ae460b"}`))
+	}))
+	defer ts.Close()
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Messages.Send(context.Background(), Message{Message: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Fatalf("want status code %d, have %d", want, have)
+	}
+	if want, have := "647d2300-702c-4b38-8b2f-d56326ae460b", resp.Request; want != have {
+		t.Fatalf("want request %q, have %q", want, have)
+	}
+	if want, have := (Limits{Limit: 10000, Remaining: 9999, Reset: 1600000000, ResetTime: time.Unix(1600000000, 0)}), resp.Limits; want != have {
+		t.Fatalf("want limits %+v, have %+v", want, have)
+	}
+	if want, have := "application/json", resp.Header.Get("Content-Type"); want != have {
+		t.Fatalf("want content type %q, have %q", want, have)
+	}
+}
@@ -0,0 +1,330 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics is called by the Client to record metrics of API calls,
+// e.g. to export them to Prometheus with PrometheusMetrics.
+//
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveRequest is called for every API call with the endpoint,
+	// e.g. "messages" or "receipts/cancel", the HTTP status code of the
+	// response, or 0 if no response was received, and the duration.
+	ObserveRequest(endpoint string, statusCode int, duration time.Duration)
+	// ObserveMessage is called for every message sent with the priority
+	// of the message and the result, which is "sent" or the category
+	// of the error, e.g. "invalid_user" or "quota_exceeded".
+	ObserveMessage(priority Priority, result string)
+	// SetLimits is called with the API limits of an application when
+	// they are reported by an API call. The application is the name
+	// registered with WithApp, "default" for the App Token of the
+	// client, or "unregistered" for other App Tokens, e.g. of
+	// Message.Token.
+	SetLimits(app string, limits Limits)
+}
+
+// WithMetrics specifies the Metrics to record API calls with.
+func WithMetrics(m Metrics) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// -- nopMetrics --
+
+// nopMetrics doesn't record anything.
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(string, int, time.Duration) {}
+func (nopMetrics) ObserveMessage(Priority, string)           {}
+func (nopMetrics) SetLimits(string, Limits)                  {}
+
+// endpointOf returns the name of the API endpoint of path, e.g.
+// "messages" for "/1/messages.json". IDs such as receipts are
+// removed, so the number of endpoints is bounded.
+func endpointOf(path string) string {
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "/1/"), ".json")
+	switch {
+	case path == "messages":
+		return "messages"
+	case path == "apps/limits":
+		return "limits"
+	case strings.HasPrefix(path, "receipts/cancel_by_tag/"):
+		return "receipts/cancel_by_tag"
+	case strings.HasPrefix(path, "receipts/") && strings.HasSuffix(path, "/cancel"):
+		return "receipts/cancel"
+	case strings.HasPrefix(path, "receipts/"):
+		return "receipts"
+	}
+	return path
+}
+
+// priorityLabel returns the name of p, e.g. "normal".
+func priorityLabel(p Priority) string {
+	switch p {
+	case Lowest:
+		return "lowest"
+	case Low:
+		return "low"
+	case Normal:
+		return "normal"
+	case High:
+		return "high"
+	case Emergency:
+		return "emergency"
+	}
+	return strconv.Itoa(int(p))
+}
+
+// resultOf returns "sent" if err is nil, or the category of err.
+func resultOf(err error) string {
+	if err == nil {
+		return "sent"
+	}
+	for _, c := range []struct {
+		err  error
+		name string
+	}{
+		{ErrInvalidToken, "invalid_token"},
+		{ErrInvalidUser, "invalid_user"},
+		{ErrInvalidDevice, "invalid_device"},
+		{ErrMessageRequired, "message_required"},
+		{ErrMessageTooLong, "message_too_long"},
+		{ErrQuotaExceeded, "quota_exceeded"},
+	} {
+		if errors.Is(err, c.err) {
+			return c.name
+		}
+	}
+	if IsContextErr(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "canceled"
+	}
+	var ae *APIError
+	if errors.As(err, &ae) {
+		if ae.StatusCode >= 500 {
+			return "server_error"
+		}
+		return "client_error"
+	}
+	return "error"
+}
+
+// -- PrometheusMetrics --
+
+// DefaultDurationBuckets are the default buckets of the histogram of
+// request durations, in seconds.
+var DefaultDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics records metrics and renders them in the Prometheus
+// text exposition format. It implements http.Handler, so it can be
+// served e.g. at "/metrics".
+//
+// These metrics are recorded:
+//
+//	pushover_requests_total{endpoint,status}             counter
+//	pushover_request_duration_seconds{endpoint}          histogram
+//	pushover_messages_total{priority,result}             counter
+//	pushover_app_limit{app}                              gauge
+//	pushover_app_remaining{app}                          gauge
+//	pushover_app_reset_timestamp_seconds{app}            gauge
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[[2]string]float64
+	durations map[string]*histogram
+	messages  map[[2]string]float64
+	limits    map[string]Limits
+}
+
+// histogram is a Prometheus histogram.
+type histogram struct {
+	counts []float64 // per bucket, not cumulative
+	sum    float64
+	count  float64
+}
+
+// NewPrometheusMetrics creates a new PrometheusMetrics. If no buckets
+// are given, DefaultDurationBuckets are used.
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &PrometheusMetrics{
+		buckets:   buckets,
+		requests:  make(map[[2]string]float64),
+		durations: make(map[string]*histogram),
+		messages:  make(map[[2]string]float64),
+		limits:    make(map[string]Limits),
+	}
+}
+
+// ObserveRequest implements the Metrics interface.
+func (m *PrometheusMetrics) ObserveRequest(endpoint string, statusCode int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{endpoint, strconv.Itoa(statusCode)}]++
+	h, found := m.durations[endpoint]
+	if !found {
+		h = &histogram{counts: make([]float64, len(m.buckets))}
+		m.durations[endpoint] = h
+	}
+	secs := duration.Seconds()
+	for i, le := range m.buckets {
+		if secs <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// ObserveMessage implements the Metrics interface.
+func (m *PrometheusMetrics) ObserveMessage(priority Priority, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages[[2]string{priorityLabel(priority), result}]++
+}
+
+// SetLimits implements the Metrics interface.
+func (m *PrometheusMetrics) SetLimits(app string, limits Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits[app] = limits
+}
+
+// ServeHTTP renders the metrics in the Prometheus text exposition format.
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format to w.
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	m.mu.Lock()
+	writeHeader(&buf, "pushover_requests_total", "counter", "Number of API calls by endpoint and HTTP status code.")
+	for _, k := range sortedKeys2(m.requests) {
+		writeSample(&buf, "pushover_requests_total", []string{"endpoint", k[0], "status", k[1]}, m.requests[k])
+	}
+
+	writeHeader(&buf, "pushover_request_duration_seconds", "histogram", "Duration of API calls by endpoint.")
+	endpoints := make([]string, 0, len(m.durations))
+	for endpoint := range m.durations {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		h := m.durations[endpoint]
+		var cumulative float64
+		for i, le := range m.buckets {
+			cumulative += h.counts[i]
+			writeSample(&buf, "pushover_request_duration_seconds_bucket", []string{"endpoint", endpoint, "le", formatFloat(le)}, cumulative)
+		}
+		writeSample(&buf, "pushover_request_duration_seconds_bucket", []string{"endpoint", endpoint, "le", "+Inf"}, h.count)
+		writeSample(&buf, "pushover_request_duration_seconds_sum", []string{"endpoint", endpoint}, h.sum)
+		writeSample(&buf, "pushover_request_duration_seconds_count", []string{"endpoint", endpoint}, h.count)
+	}
+
+	writeHeader(&buf, "pushover_messages_total", "counter", "Number of messages by priority and result.")
+	for _, k := range sortedKeys2(m.messages) {
+		writeSample(&buf, "pushover_messages_total", []string{"priority", k[0], "result", k[1]}, m.messages[k])
+	}
+
+	apps := make([]string, 0, len(m.limits))
+	for app := range m.limits {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	writeHeader(&buf, "pushover_app_limit", "gauge", "Monthly message limit of the application.")
+	for _, app := range apps {
+		writeSample(&buf, "pushover_app_limit", []string{"app", app}, float64(m.limits[app].Limit))
+	}
+	writeHeader(&buf, "pushover_app_remaining", "gauge", "Remaining messages of the application in the current month.")
+	for _, app := range apps {
+		writeSample(&buf, "pushover_app_remaining", []string{"app", app}, float64(m.limits[app].Remaining))
+	}
+	writeHeader(&buf, "pushover_app_reset_timestamp_seconds", "gauge", "Time when the limit of the application is reset.")
+	for _, app := range apps {
+		writeSample(&buf, "pushover_app_reset_timestamp_seconds", []string{"app", app}, float64(m.limits[app].Reset))
+	}
+	m.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+// sortedKeys2 returns the keys of m in sorted order.
+func sortedKeys2(m map[[2]string]float64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric.
+func writeHeader(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, typ)
+}
+
+// writeSample writes a sample of a metric. Labels are given as
+// alternating names and values.
+func writeSample(buf *bytes.Buffer, name string, labels []string, value float64) {
+	buf.WriteString(name)
+	if len(labels) > 0 {
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(labels[i])
+			buf.WriteString(`="`)
+			buf.WriteString(labelEscaper.Replace(labels[i+1]))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(formatFloat(value))
+	buf.WriteByte('\n')
+}
+
+// labelEscaper escapes label values in the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat formats v in the text exposition format.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
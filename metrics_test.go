@@ -0,0 +1,169 @@
+package pushover
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "7500")
+		w.Header().Set("X-Limit-App-Reset", "1600000000")
+		if r.FormValue("user") == "invalid" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"user":"invalid","errors":["user identifier is invalid"],"status":0,"request":"2"}`))
+			return
+		}
+		w.Write([]byte(`{"status":1,"request":"1"}`))
+	}))
+	defer ts.Close()
+
+	metrics := NewPrometheusMetrics()
+	client, err := NewClient(
+		WithURL(ts.URL),
+		WithAppToken("token"),
+		WithUserKey("user"),
+		WithApp("alerts", "alerts-token"),
+		WithMetrics(metrics),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := client.Messages.Send(ctx, Message{Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(ctx, Message{Message: "Alert", App: "alerts", Priority: High}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(ctx, Message{Message: "Hello", User: "invalid"}); err == nil {
+		t.Fatal("want error, have nil")
+	}
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if want, have := "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"); want != have {
+		t.Fatalf("want content type %q, have %q", want, have)
+	}
+	out := w.Body.String()
+	for _, line := range []string{
+		"# TYPE pushover_requests_total counter",
+		`pushover_requests_total{endpoint="messages",status="200"} 2`,
+		`pushover_requests_total{endpoint="messages",status="400"} 1`,
+		"# TYPE pushover_request_duration_seconds histogram",
+		`pushover_request_duration_seconds_bucket{endpoint="messages",le="+Inf"} 3`,
+		`pushover_request_duration_seconds_count{endpoint="messages"} 3`,
+		`pushover_messages_total{priority="high",result="sent"} 1`,
+		`pushover_messages_total{priority="normal",result="invalid_user"} 1`,
+		`pushover_messages_total{priority="normal",result="sent"} 1`,
+		"# TYPE pushover_app_remaining gauge",
+		`pushover_app_limit{app="alerts"} 10000`,
+		`pushover_app_remaining{app="default"} 7500`,
+		`pushover_app_reset_timestamp_seconds{app="default"} 1.6e+09`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("want %q in output, have:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "alerts-token") {
+		t.Fatal("want App Tokens not to be exported")
+	}
+}
+
+func TestEndpointOf(t *testing.T) {
+	tests := []struct {
+		Path string
+		Want string
+	}{
+		{"/1/messages.json", "messages"},
+		{"/1/apps/limits.json", "limits"},
+		{"/1/receipts/r123.json", "receipts"},
+		{"/1/receipts/r123/cancel.json", "receipts/cancel"},
+		{"/1/receipts/cancel_by_tag/db.json", "receipts/cancel_by_tag"},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Want, endpointOf(tt.Path); want != have {
+			t.Errorf("endpointOf(%q): want %q, have %q", tt.Path, want, have)
+		}
+	}
+}
+
+// limitsMetrics records the limits of the client when they are set.
+type limitsMetrics struct {
+	nopMetrics
+	c *Client
+
+	mu   sync.Mutex
+	apps []string
+}
+
+func (m *limitsMetrics) SetLimits(app string, limits Limits) {
+	// Reading the limits of the client must not deadlock
+	m.c.Limits()
+	m.c.AppLimits("alerts")
+	m.mu.Lock()
+	m.apps = append(m.apps, app)
+	m.mu.Unlock()
+}
+
+func TestMetricsSetLimits(t *testing.T) {
+	ts := newLimitsTestServer(t)
+	metrics := &limitsMetrics{}
+	client, err := NewClient(
+		WithURL(ts.URL),
+		WithAppToken("token"),
+		WithUserKey("user"),
+		WithApp("alerts", "alerts-token"),
+		WithApp("pages", "alerts-token"),
+		WithMetrics(metrics),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics.c = client
+
+	ctx := context.Background()
+	for _, m := range []Message{
+		{Message: "Hello"},
+		{Message: "Alert", App: "pages"},
+		{Message: "Other", Token: "other-token"},
+	} {
+		done := make(chan error, 1)
+		go func() {
+			_, err := client.Messages.Send(ctx, m)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("deadlock in SetLimits")
+		}
+	}
+	if want, have := []string{"default", "alerts", "unregistered"}, metrics.apps; !reflect.DeepEqual(want, have) {
+		t.Fatalf("want apps %v, have %v", want, have)
+	}
+}
+
+func newLimitsTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "7500")
+		w.Header().Set("X-Limit-App-Reset", "1600000000")
+		w.Write([]byte(`{"status":1,"request":"1"}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
@@ -0,0 +1,118 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientDoTransportError(t *testing.T) {
+	failure := errors.New("connection refused")
+	failing := RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, failure
+	})
+	for _, logger := range []Logger{nopLogger{}, NewJSONLogger(&bytes.Buffer{}), NewRawLogger(&bytes.Buffer{})} {
+		client, err := NewClient(WithURL("http://localhost"), WithAppToken("token"), WithUserKey("user"), WithTransport(failing), WithLogger(logger))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Messages.Send(context.Background(), Message{Message: "Hello"})
+		if !errors.Is(err, failure) {
+			t.Fatalf("%T: want %v, have %v", logger, failure, err)
+		}
+	}
+}
+
+func TestWithMiddleware(t *testing.T) {
+	var headers http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":1,"request":"1"}`))
+	}))
+	defer ts.Close()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	client, err := NewClient(
+		WithURL(ts.URL),
+		WithAppToken("token"),
+		WithUserKey("user"),
+		WithMiddleware(trace("first"), trace("second")),
+		WithMiddleware(
+			RequestIDMiddleware(""),
+			HeaderMiddleware(http.Header{"x-proxy-auth": {"secret"}}),
+			TimeoutMiddleware(time.Second),
+		),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "first,second", strings.Join(order, ","); want != have {
+		t.Fatalf("want order %q, have %q", want, have)
+	}
+	if want, have := 32, len(headers.Get(DefaultRequestIDHeader)); want != have {
+		t.Fatalf("want request ID of length %d, have %q", want, headers.Get(DefaultRequestIDHeader))
+	}
+	if want, have := "secret", headers.Get("X-Proxy-Auth"); want != have {
+		t.Fatalf("want header %q, have %q", want, have)
+	}
+}
+
+func TestRequestIDMiddlewareKeepsExistingID(t *testing.T) {
+	var have string
+	tr := RequestIDMiddleware("X-Correlation-Id")(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		have = req.Header.Get("X-Correlation-Id")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+	req := httptest.NewRequest("GET", "http://localhost", nil)
+	req.Header.Set("X-Correlation-Id", "abc")
+	if _, err := tr.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if want := "abc"; want != have {
+		t.Fatalf("want request ID %q, have %q", want, have)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	client, err := NewClient(
+		WithURL(ts.URL),
+		WithAppToken("token"),
+		WithUserKey("user"),
+		WithMiddleware(TimeoutMiddleware(50*time.Millisecond)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = client.Messages.Send(context.Background(), Message{Message: "Hello"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("want request to time out, took %v", d)
+	}
+}
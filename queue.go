@@ -0,0 +1,635 @@
+package pushover
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultQueueMinRetryInterval is the default minimum interval
+	// between two delivery attempts of a queued message.
+	DefaultQueueMinRetryInterval = 5 * time.Second
+	// DefaultQueueMaxRetryInterval is the default maximum interval
+	// between two delivery attempts of a queued message.
+	DefaultQueueMaxRetryInterval = 10 * time.Minute
+
+	queueEntryExt      = ".json"
+	queueDoneExt       = ".done"
+	queueFailedExt     = ".failed"
+	queueAttachmentExt = ".d"
+	queueTempExt       = ".tmp"
+)
+
+// ErrQueueClosed is returned when using a Queue that is closed.
+var ErrQueueClosed = errors.New("pushover: queue is closed")
+
+// ErrQueueCredentials is returned when enqueuing a message with a user
+// key or an App Token that is not registered with WithApp, as the queue
+// doesn't write credentials to its spool.
+var ErrQueueCredentials = errors.New("pushover: unable to queue message with credentials")
+
+// Queue is a durable queue of outbound messages.
+//
+// Messages are persisted to a local directory (the spool) before they
+// are delivered, so they survive network outages and restarts. Each
+// message, including a copy of its attachment, is written atomically,
+// so a crash never leaves a partially written message behind.
+//
+// Messages are delivered in the background in the order they were
+// enqueued. Failed deliveries are retried with exponential backoff,
+// and delivery pauses while the API limits of the application are
+// exceeded. Messages rejected by Pushover, e.g. because of an invalid
+// user key, are kept in the spool with a ".failed" extension.
+//
+// Messages are identified by their contents, including the timestamp,
+// so enqueuing the same message again while it is still pending, e.g.
+// after a restart, delivers it only once.
+//
+// The spool is only accessible by its owner, and credentials are never
+// written to it: Messages are sent with the user key of the Client, and
+// with the App Token of the Client or of an application registered with
+// WithApp. Messages with a User or an unregistered Token are rejected
+// with ErrQueueCredentials.
+type Queue struct {
+	c          *Client
+	dir        string
+	minRetry   time.Duration
+	maxRetry   time.Duration
+	background bool
+	onError    func(error)
+
+	sendMu sync.Mutex // serializes delivery
+	wake   chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex // guards closed and seq, and serializes Enqueue
+	closed bool
+	seq    uint64 // sequence number of the last enqueued message
+}
+
+// QueueOption for configuring Queue settings.
+type QueueOption func(*Queue)
+
+// WithQueueRetryInterval sets the minimum and maximum interval between
+// two delivery attempts of a message.
+func WithQueueRetryInterval(min, max time.Duration) QueueOption {
+	return func(q *Queue) {
+		q.minRetry = min
+		q.maxRetry = max
+	}
+}
+
+// WithQueueBackground enables or disables delivery of messages in the
+// background (default: true). If disabled, messages are only delivered
+// by calling Flush.
+func WithQueueBackground(enabled bool) QueueOption {
+	return func(q *Queue) {
+		q.background = enabled
+	}
+}
+
+// WithQueueErrorHandler specifies a function that is called when
+// delivering a message in the background fails, or when a message is
+// rejected by Pushover and will not be delivered.
+func WithQueueErrorHandler(fn func(error)) QueueOption {
+	return func(q *Queue) {
+		q.onError = fn
+	}
+}
+
+// QueueEntry is a message in the spool of a Queue.
+type QueueEntry struct {
+	// ID of the entry. It starts with a sequence number, so entries
+	// are delivered in the order they were enqueued, regardless of
+	// the clock.
+	ID string `json:"id"`
+	// Message to deliver.
+	Message Message `json:"message"`
+	// EnqueuedAt is the time when the message was enqueued.
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// Attempts is the number of failed delivery attempts.
+	Attempts int `json:"attempts,omitempty"`
+	// NextAttempt is the earliest time of the next delivery attempt.
+	NextAttempt time.Time `json:"next_attempt,omitempty"`
+	// LastError is the error of the last failed delivery attempt.
+	LastError string `json:"last_error,omitempty"`
+	// Failed is true if the message was rejected by Pushover and
+	// will not be delivered.
+	Failed bool `json:"-"`
+}
+
+// NewQueue creates a Queue that spools messages to dir and delivers
+// them with c. The directory is created if it doesn't exist. Messages
+// left in the spool, e.g. after a restart, are delivered as well.
+func NewQueue(c *Client, dir string, options ...QueueOption) (*Queue, error) {
+	q := &Queue{
+		c:          c,
+		dir:        dir,
+		minRetry:   DefaultQueueMinRetryInterval,
+		maxRetry:   DefaultQueueMaxRetryInterval,
+		background: true,
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	for _, o := range options {
+		o(q)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create queue directory: %w", err)
+	}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	if q.background {
+		q.wg.Add(1)
+		go q.run()
+	}
+	return q, nil
+}
+
+// Enqueue persists m in the spool and schedules it for delivery.
+// It returns the ID of the queued message.
+//
+// If m has no timestamp, it is set to the current time, so the message
+// shows the time it was enqueued rather than the time it was delivered.
+// The attachment of m, if any, is copied into the spool.
+func (q *Queue) Enqueue(m Message) (string, error) {
+	m, err := q.withoutCredentials(m)
+	if err != nil {
+		return "", err
+	}
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+	m.Timestamp = m.Timestamp.Truncate(time.Second)
+
+	var attachment []byte
+	if m.Attachment != "" {
+		attachment, err = ioutil.ReadFile(m.Attachment)
+		if err != nil {
+			return "", fmt.Errorf("unable to read attachment: %w", err)
+		}
+		m.Attachment = filepath.Base(m.Attachment)
+	}
+
+	hash, err := queueHash(m, attachment)
+	if err != nil {
+		return "", err
+	}
+
+	// Hold the lock until the entry is written, so the same message
+	// enqueued concurrently is written only once
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return "", ErrQueueClosed
+	}
+	if id, found := q.find(hash); found {
+		return id, nil
+	}
+	q.seq++
+	id := fmt.Sprintf("%016d-%s", q.seq, hash)
+
+	if attachment != nil {
+		dir := filepath.Join(q.dir, id+queueAttachmentExt)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("unable to create attachment directory: %w", err)
+		}
+		if err := writeFileAtomic(filepath.Join(dir, m.Attachment), attachment); err != nil {
+			return "", fmt.Errorf("unable to write attachment: %w", err)
+		}
+	}
+	e := QueueEntry{
+		ID:         id,
+		Message:    m,
+		EnqueuedAt: time.Now(),
+	}
+	if err := q.write(e); err != nil {
+		return "", err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return id, nil
+}
+
+// Pending returns the entries in the spool in the order of delivery,
+// including the entries that failed permanently.
+func (q *Queue) Pending() ([]QueueEntry, error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read queue directory: %w", err)
+	}
+	var entries []QueueEntry
+	for _, fi := range files {
+		ext := filepath.Ext(fi.Name())
+		if fi.IsDir() || (ext != queueEntryExt && ext != queueFailedExt) {
+			continue
+		}
+		e, err := q.read(fi.Name())
+		if err != nil {
+			return nil, err
+		}
+		e.Failed = ext == queueFailedExt
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		// IDs start with the zero-padded sequence number
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// Flush delivers all pending messages, ignoring the retry intervals of
+// messages that failed before. It returns when all messages are
+// delivered, or with the error of the first message that could not be
+// delivered, e.g. ErrQuotaExceeded if the API limits are exceeded.
+// Messages that failed permanently are not retried.
+func (q *Queue) Flush(ctx context.Context) error {
+	return q.deliver(ctx, true)
+}
+
+// Close stops delivering messages in the background. Messages that
+// are not delivered yet remain in the spool.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	close(q.done)
+	q.wg.Wait()
+	return nil
+}
+
+// run delivers messages in the background until the queue is closed.
+func (q *Queue) run() {
+	defer q.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-q.done
+		cancel()
+	}()
+
+	for {
+		if err := q.deliver(ctx, false); err != nil && ctx.Err() == nil {
+			q.report(err)
+		}
+
+		wait := q.maxRetry
+		if next, ok := q.nextAttempt(); ok {
+			wait = time.Until(next)
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-q.done:
+			t.Stop()
+			return
+		case <-q.wake:
+			t.Stop()
+		case <-t.C:
+		}
+	}
+}
+
+// deliver sends all pending messages that are due, in order. If force
+// is true, all pending messages are due. It stops at the first message
+// that cannot be delivered, to preserve the order of messages.
+func (q *Queue) deliver(ctx context.Context, force bool) error {
+	q.sendMu.Lock()
+	defer q.sendMu.Unlock()
+
+	entries, err := q.Pending()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Failed {
+			continue
+		}
+		if !force && time.Now().Before(e.NextAttempt) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := q.quotaExceeded(e.Message); err != nil {
+			return err
+		}
+
+		m := e.Message
+		if m.Attachment != "" {
+			m.Attachment = filepath.Join(q.dir, e.ID+queueAttachmentExt, m.Attachment)
+		}
+		_, err := q.c.Messages.Send(ContextWithAttempt(ctx, e.Attempts+1), m)
+		if err == nil {
+			if err := q.remove(e.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if IsContextErr(err) && ctx.Err() != nil {
+			return err
+		}
+
+		err = fmt.Errorf("unable to deliver queued message %s: %w", e.ID, err)
+		e.Attempts++
+		e.LastError = err.Error()
+		if isPermanentError(err) {
+			if err := q.fail(e); err != nil {
+				return err
+			}
+			q.report(err)
+			continue
+		}
+		e.NextAttempt = time.Now().Add(q.backoff(e.Attempts))
+		if IsStatusCode(err, http.StatusTooManyRequests) {
+			if l := q.limits(e.Message); !l.ResetTime.IsZero() && l.ResetTime.After(e.NextAttempt) {
+				e.NextAttempt = l.ResetTime
+			}
+		}
+		if werr := q.write(e); werr != nil {
+			return werr
+		}
+		return err
+	}
+	return nil
+}
+
+// report calls the error handler of the queue, if any.
+func (q *Queue) report(err error) {
+	if q.onError != nil {
+		q.onError(err)
+	}
+}
+
+// withoutCredentials returns m without credentials, so it can be written
+// to the spool. The App Token of m is replaced with the name of its
+// application, if it is registered with WithApp.
+func (q *Queue) withoutCredentials(m Message) (Message, error) {
+	if m.User != "" {
+		return m, fmt.Errorf("%w: user key overrides are not supported", ErrQueueCredentials)
+	}
+	if m.Token != "" {
+		name, found := q.c.appOf(m.Token)
+		if !found {
+			return m, fmt.Errorf("%w: App Token is not registered with WithApp", ErrQueueCredentials)
+		}
+		m.App, m.Token = name, ""
+	}
+	return m, nil
+}
+
+// quotaExceeded returns ErrQuotaExceeded if the application used to
+// send m has no remaining API calls.
+func (q *Queue) quotaExceeded(m Message) error {
+	appToken, err := q.c.appTokenOf(m.App)
+	if err != nil {
+		return nil // reported when sending
+	}
+	if m.Token != "" {
+		appToken = m.Token
+	}
+	if q.c.quotaExceeded(appToken) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// limits returns the limits of the application used to send m.
+func (q *Queue) limits(m Message) Limits {
+	if m.Token != "" {
+		return q.c.limitsOf(m.Token)
+	}
+	return q.c.AppLimits(m.App)
+}
+
+// nextAttempt returns the earliest time of the next delivery attempt.
+func (q *Queue) nextAttempt() (time.Time, bool) {
+	entries, err := q.Pending()
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, e := range entries {
+		if e.Failed {
+			continue
+		}
+		next := e.NextAttempt
+		if l := q.limits(e.Message); l.Limit > 0 && l.Remaining <= 0 && l.ResetTime.After(next) {
+			next = l.ResetTime
+		}
+		return next, true
+	}
+	return time.Time{}, false
+}
+
+// backoff returns the interval before the next delivery attempt.
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.minRetry
+	for i := 1; i < attempts && d < q.maxRetry; i++ {
+		d *= 2
+	}
+	if d > q.maxRetry {
+		d = q.maxRetry
+	}
+	return d
+}
+
+// isPermanentError returns true if retrying a message that failed
+// with err is pointless, e.g. because the request was invalid.
+func isPermanentError(err error) bool {
+	var ae *APIError
+	if errors.As(err, &ae) {
+		return ae.StatusCode >= 400 && ae.StatusCode < 500 && ae.StatusCode != http.StatusTooManyRequests
+	}
+	var pe *os.PathError
+	return errors.As(err, &pe)
+}
+
+// recover cleans up the spool after a restart: It removes temporary
+// files, messages that were delivered but not yet removed, and
+// attachments without a message.
+func (q *Queue) recover() error {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("unable to read queue directory: %w", err)
+	}
+	ids := make(map[string]bool)
+	for _, fi := range files {
+		switch ext := filepath.Ext(fi.Name()); ext {
+		case queueEntryExt, queueFailedExt:
+			id := strings.TrimSuffix(fi.Name(), ext)
+			ids[id] = true
+			if seq, err := strconv.ParseUint(strings.SplitN(id, "-", 2)[0], 10, 64); err == nil && seq > q.seq {
+				q.seq = seq
+			}
+		}
+	}
+	for _, fi := range files {
+		name := fi.Name()
+		switch ext := filepath.Ext(name); ext {
+		case queueTempExt:
+			os.Remove(filepath.Join(q.dir, name))
+		case queueDoneExt:
+			if err := q.remove(strings.TrimSuffix(name, ext)); err != nil {
+				return err
+			}
+		case queueAttachmentExt:
+			if !ids[strings.TrimSuffix(name, ext)] {
+				os.RemoveAll(filepath.Join(q.dir, name))
+			}
+		}
+	}
+	return nil
+}
+
+// find returns the ID of the message with the given hash of its
+// contents, if it is in the spool.
+func (q *Queue) find(hash string) (string, bool) {
+	for _, ext := range []string{queueEntryExt, queueFailedExt} {
+		matches, _ := filepath.Glob(filepath.Join(q.dir, "*-"+hash+ext))
+		if len(matches) > 0 {
+			return strings.TrimSuffix(filepath.Base(matches[0]), ext), true
+		}
+	}
+	return "", false
+}
+
+// read the entry stored in the file with the given name.
+func (q *Queue) read(name string) (QueueEntry, error) {
+	var e QueueEntry
+	data, err := ioutil.ReadFile(filepath.Join(q.dir, name))
+	if err != nil {
+		return e, fmt.Errorf("unable to read queue entry: %w", err)
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, fmt.Errorf("unable to decode queue entry %s: %w", name, err)
+	}
+	return e, nil
+}
+
+// write the entry atomically to the spool. Credentials are never
+// written, see withoutCredentials.
+func (q *Queue) write(e QueueEntry) error {
+	e.Message.User, e.Message.Token = "", ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("unable to encode queue entry: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(q.dir, e.ID+queueEntryExt), data); err != nil {
+		return fmt.Errorf("unable to write queue entry: %w", err)
+	}
+	return nil
+}
+
+// fail marks the entry as failed permanently.
+func (q *Queue) fail(e QueueEntry) error {
+	if err := q.write(e); err != nil {
+		return err
+	}
+	return os.Rename(
+		filepath.Join(q.dir, e.ID+queueEntryExt),
+		filepath.Join(q.dir, e.ID+queueFailedExt),
+	)
+}
+
+// remove a delivered message from the spool. The message is first
+// marked as delivered, so it is not delivered again if removing its
+// attachment is interrupted.
+func (q *Queue) remove(id string) error {
+	entry := filepath.Join(q.dir, id+queueEntryExt)
+	done := filepath.Join(q.dir, id+queueDoneExt)
+	if err := os.Rename(entry, done); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove queue entry: %w", err)
+	}
+	syncDir(q.dir)
+	if err := os.RemoveAll(filepath.Join(q.dir, id+queueAttachmentExt)); err != nil {
+		return fmt.Errorf("unable to remove attachment: %w", err)
+	}
+	if err := os.Remove(done); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove queue entry: %w", err)
+	}
+	return nil
+}
+
+// queueHash returns the hash of the contents of a message, which
+// identifies duplicates.
+func queueHash(m Message, attachment []byte) (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode message: %w", err)
+	}
+	h := sha256.New()
+	h.Write(data)
+	h.Write(attachment)
+	return hex.EncodeToString(h.Sum(nil))[:32], nil
+}
+
+// writeFileAtomic writes data to a temporary file, syncs it to disk
+// and renames it to filename, so filename is either written completely
+// or not at all. The file is only accessible by its owner.
+func writeFileAtomic(filename string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*"+queueTempExt)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(filename))
+	return nil
+}
+
+// syncDir syncs the directory, so renames within it are persisted.
+// Errors are ignored, as not all platforms support syncing directories.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,400 @@
+package pushover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queueTestServer records the messages it receives and responds with
+// the configured status code.
+type queueTestServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	status      int
+	messages    []string
+	attachments []string
+}
+
+func newQueueTestServer(t *testing.T) *queueTestServer {
+	t.Helper()
+	s := &queueTestServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(s.status)
+		if s.status != http.StatusOK {
+			w.Write([]byte(`{"status":0,"errors":["failed"]}`))
+			return
+		}
+		s.messages = append(s.messages, r.FormValue("message"))
+		if r.MultipartForm != nil {
+			for _, fh := range r.MultipartForm.File["attachment"] {
+				s.attachments = append(s.attachments, fh.Filename)
+			}
+		}
+		w.Write([]byte(`{"status":1,"request":"1"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *queueTestServer) setStatus(status int) {
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+}
+
+func (s *queueTestServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.messages...)
+}
+
+func newTestQueue(t *testing.T, ts *queueTestServer, dir string, options ...QueueOption) *Queue {
+	t.Helper()
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(client, dir, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func TestQueueFlush(t *testing.T) {
+	ts := newQueueTestServer(t)
+	dir := t.TempDir()
+	q := newTestQueue(t, ts, dir, WithQueueBackground(false))
+
+	attachment := filepath.Join(t.TempDir(), "avatar.png")
+	if err := ioutil.WriteFile(attachment, []byte("PNG"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Message{
+		{Message: "first"},
+		{Message: "second", Attachment: attachment},
+		{Message: "third"},
+	} {
+		if _, err := q.Enqueue(m); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// The original attachment may be removed after enqueuing
+	if err := os.Remove(attachment); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, len(entries); want != have {
+		t.Fatalf("want %d pending entries, have %d", want, have)
+	}
+
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"first", "second", "third"}, ts.received(); len(want) != len(have) || want[0] != have[0] || want[1] != have[1] || want[2] != have[2] {
+		t.Fatalf("want messages %v, have %v", want, have)
+	}
+	if want, have := []string{"avatar.png"}, ts.attachments; len(want) != len(have) || want[0] != have[0] {
+		t.Fatalf("want attachments %v, have %v", want, have)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("want empty spool, have %d files", len(files))
+	}
+}
+
+func TestQueueRetry(t *testing.T) {
+	ts := newQueueTestServer(t)
+	ts.setStatus(http.StatusServiceUnavailable)
+	q := newTestQueue(t, ts, t.TempDir(), WithQueueBackground(false))
+
+	if _, err := q.Enqueue(Message{Message: "retry me"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Flush(context.Background()); err == nil {
+		t.Fatal("want error on flush, have nil")
+	}
+	entries, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(entries); want != have {
+		t.Fatalf("want %d pending entries, have %d", want, have)
+	}
+	if want, have := 1, entries[0].Attempts; want != have {
+		t.Fatalf("want %d attempts, have %d", want, have)
+	}
+	if entries[0].NextAttempt.IsZero() || entries[0].LastError == "" {
+		t.Fatalf("want next attempt and last error to be set, have %+v", entries[0])
+	}
+
+	ts.setStatus(http.StatusOK)
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(ts.received()); want != have {
+		t.Fatalf("want %d messages, have %d", want, have)
+	}
+}
+
+func TestQueuePermanentFailure(t *testing.T) {
+	ts := newQueueTestServer(t)
+	ts.setStatus(http.StatusBadRequest)
+	q := newTestQueue(t, ts, t.TempDir(), WithQueueBackground(false))
+
+	if _, err := q.Enqueue(Message{Message: "invalid"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatalf("want no error for permanent failures, have %v", err)
+	}
+	entries, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(entries); want != have {
+		t.Fatalf("want %d entries, have %d", want, have)
+	}
+	if !entries[0].Failed {
+		t.Fatal("want entry to be failed")
+	}
+}
+
+func TestQueueDeduplicate(t *testing.T) {
+	ts := newQueueTestServer(t)
+	dir := t.TempDir()
+	q := newTestQueue(t, ts, dir, WithQueueBackground(false))
+
+	m := Message{Message: "only once", Timestamp: time.Unix(1600000000, 0)}
+	id1, err := q.Enqueue(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	// Simulate a restart with leftovers of a crash
+	for _, name := range []string{"abc.json.123.tmp", "delivered.done"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "orphan.d"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	q = newTestQueue(t, ts, dir, WithQueueBackground(false))
+	id2, err := q.Enqueue(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 != id2 {
+		t.Fatalf("want same ID, have %q and %q", id1, id2)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(files); want != have {
+		t.Fatalf("want %d file in spool, have %d", want, have)
+	}
+
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(ts.received()); want != have {
+		t.Fatalf("want %d messages, have %d", want, have)
+	}
+}
+
+func TestQueueBackground(t *testing.T) {
+	ts := newQueueTestServer(t)
+	q := newTestQueue(t, ts, t.TempDir())
+
+	if _, err := q.Enqueue(Message{Message: "in the background"}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ts.received()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("message not delivered in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Enqueue(Message{Message: "closed"}); err != ErrQueueClosed {
+		t.Fatalf("want %v, have %v", ErrQueueClosed, err)
+	}
+}
+
+func TestQueueSpoolWithoutCredentials(t *testing.T) {
+	ts := newQueueTestServer(t)
+	dir := t.TempDir()
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"), WithApp("deploys", "deploys-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(client, dir, WithQueueBackground(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+
+	if _, err := q.Enqueue(Message{Message: "unregistered", Token: "secret-token"}); !errors.Is(err, ErrQueueCredentials) {
+		t.Fatalf("want %v, have %v", ErrQueueCredentials, err)
+	}
+	if _, err := q.Enqueue(Message{Message: "user", User: "secret-user"}); !errors.Is(err, ErrQueueCredentials) {
+		t.Fatalf("want %v, have %v", ErrQueueCredentials, err)
+	}
+	if _, err := q.Enqueue(Message{Message: "deployed", Token: "deploys-token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(entries); want != have {
+		t.Fatalf("want %d pending entries, have %d", want, have)
+	}
+	if want, have := "deploys", entries[0].Message.App; want != have {
+		t.Fatalf("want App=%q, have %q", want, have)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range files {
+		if want, have := os.FileMode(0600), fi.Mode().Perm(); want != have {
+			t.Fatalf("want mode %v for %s, have %v", want, fi.Name(), have)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "token") {
+			t.Fatalf("want no App Token in spool, have %s", data)
+		}
+	}
+}
+
+func TestQueueOrderWithClockStep(t *testing.T) {
+	ts := newQueueTestServer(t)
+	q := newTestQueue(t, ts, t.TempDir(), WithQueueBackground(false))
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		m := fmt.Sprintf("message %02d", 19-i)
+		if _, err := q.Enqueue(Message{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, m)
+	}
+	// Simulate a clock that steps backwards between the entries
+	entries, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, e := range entries {
+		e.EnqueuedAt = now.Add(-time.Duration(i) * time.Minute)
+		if err := q.write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if have := ts.received(); !reflect.DeepEqual(want, have) {
+		t.Fatalf("want messages %v, have %v", want, have)
+	}
+}
+
+func TestQueueConcurrentEnqueue(t *testing.T) {
+	ts := newQueueTestServer(t)
+	dir := t.TempDir()
+	q := newTestQueue(t, ts, dir, WithQueueBackground(false))
+
+	m := Message{Message: "Backup finished", Timestamp: time.Unix(1600000000, 0)}
+	ids := make([]string, 20)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id, err := q.Enqueue(m)
+			if err != nil {
+				t.Error(err)
+			}
+			ids[i] = id
+		}(i)
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		if want, have := ids[0], id; want != have {
+			t.Fatalf("want ID %q, have %q", want, have)
+		}
+	}
+	entries, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(entries); want != have {
+		t.Fatalf("want %d entry, have %d", want, have)
+	}
+}
+
+func TestQueueErrorHandler(t *testing.T) {
+	ts := newQueueTestServer(t)
+	ts.setStatus(http.StatusBadRequest)
+
+	errc := make(chan error, 1)
+	q := newTestQueue(t, ts, t.TempDir(), WithQueueErrorHandler(func(err error) {
+		select {
+		case errc <- err:
+		default:
+		}
+	}))
+	id, err := q.Enqueue(Message{Message: "invalid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !IsStatusCode(err, http.StatusBadRequest) {
+			t.Fatalf("want status code %d, have %v", http.StatusBadRequest, err)
+		}
+		if !strings.Contains(err.Error(), id) {
+			t.Fatalf("want error to contain ID %s, have %v", id, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("error handler not called")
+	}
+}
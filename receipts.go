@@ -0,0 +1,157 @@
+package pushover
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type receiptsAPI struct {
+	c *Client
+}
+
+// Receipt is the status of a message with Emergency priority.
+//
+// See https://pushover.net/api/receipts for details.
+type Receipt struct {
+	Status  int    `json:"status,omitempty"`
+	Request string `json:"request,omitempty"`
+
+	// Acknowledged is true if the user has acknowledged the message.
+	Acknowledged bool `json:"-"`
+	// AcknowledgedAt is the time when the user acknowledged the message.
+	AcknowledgedAt time.Time `json:"-"`
+	// AcknowledgedBy is the user key of the user that acknowledged
+	// the message.
+	AcknowledgedBy string `json:"acknowledged_by,omitempty"`
+	// AcknowledgedByDevice is the name of the device on which the user
+	// acknowledged the message.
+	AcknowledgedByDevice string `json:"acknowledged_by_device,omitempty"`
+	// LastDeliveredAt is the time when the message was last retried.
+	LastDeliveredAt time.Time `json:"-"`
+	// Expired is true if the message expired without being acknowledged.
+	Expired bool `json:"-"`
+	// ExpiresAt is the time when the message expires.
+	ExpiresAt time.Time `json:"-"`
+	// CalledBack is true if the callback URL of the message was invoked.
+	CalledBack bool `json:"-"`
+	// CalledBackAt is the time when the callback URL was invoked.
+	CalledBackAt time.Time `json:"-"`
+}
+
+// receiptResponse is the JSON representation of a Receipt.
+type receiptResponse struct {
+	Receipt
+	Acknowledged    int   `json:"acknowledged"`
+	AcknowledgedAt  int64 `json:"acknowledged_at"`
+	LastDeliveredAt int64 `json:"last_delivered_at"`
+	Expired         int   `json:"expired"`
+	ExpiresAt       int64 `json:"expires_at"`
+	CalledBack      int   `json:"called_back"`
+	CalledBackAt    int64 `json:"called_back_at"`
+}
+
+// Get returns the status of the message with the given receipt.
+func (api *receiptsAPI) Get(ctx context.Context, receipt string) (*Receipt, error) {
+	appToken, _, err := api.c.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return api.get(ctx, receipt, appToken)
+}
+
+// get returns the status of the message with the given receipt, sent
+// with the given App Token.
+func (api *receiptsAPI) get(ctx context.Context, receipt, appToken string) (_ *Receipt, err error) {
+	ctx, end := api.c.startSpan(ctx, "pushover.receipts.get", &TraceInfo{Endpoint: "receipts"})
+	defer func() { end(err) }()
+
+	u, err := url.Parse("/1/receipts/" + url.PathEscape(receipt) + ".json")
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Add("token", appToken)
+	u.RawQuery = values.Encode()
+
+	ctx = context.WithValue(ctx, appTokenContextKey{}, appToken)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := api.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+	var ret receiptResponse
+	if err := parseResponse(resp, &ret); err != nil {
+		return nil, err
+	}
+	r := ret.Receipt
+	r.Acknowledged = ret.Acknowledged != 0
+	r.AcknowledgedAt = unixTime(ret.AcknowledgedAt)
+	r.LastDeliveredAt = unixTime(ret.LastDeliveredAt)
+	r.Expired = ret.Expired != 0
+	r.ExpiresAt = unixTime(ret.ExpiresAt)
+	r.CalledBack = ret.CalledBack != 0
+	r.CalledBackAt = unixTime(ret.CalledBackAt)
+	return &r, nil
+}
+
+// Cancel stops retrying the message with the given receipt.
+func (api *receiptsAPI) Cancel(ctx context.Context, receipt string) error {
+	appToken, _, err := api.c.credentials(ctx)
+	if err != nil {
+		return err
+	}
+	return api.cancelReceipt(ctx, receipt, appToken)
+}
+
+// cancelReceipt stops retrying the message with the given receipt, sent
+// with the given App Token.
+func (api *receiptsAPI) cancelReceipt(ctx context.Context, receipt, appToken string) error {
+	return api.cancel(ctx, "/1/receipts/"+url.PathEscape(receipt)+"/cancel.json", appToken)
+}
+
+// CancelByTag stops retrying all messages sent with the given tag.
+func (api *receiptsAPI) CancelByTag(ctx context.Context, tag string) error {
+	appToken, _, err := api.c.credentials(ctx)
+	if err != nil {
+		return err
+	}
+	return api.cancel(ctx, "/1/receipts/cancel_by_tag/"+url.PathEscape(tag)+".json", appToken)
+}
+
+// cancel the messages of the given API path, sent with the given App Token.
+func (api *receiptsAPI) cancel(ctx context.Context, path, appToken string) (err error) {
+	endpoint := endpointOf(path)
+	ctx, end := api.c.startSpan(ctx, "pushover."+strings.Replace(endpoint, "/", ".", -1), &TraceInfo{Endpoint: endpoint})
+	defer func() { end(err) }()
+
+	values := url.Values{}
+	values.Add("token", appToken)
+	ctx = context.WithValue(ctx, appTokenContextKey{}, appToken)
+	req, err := http.NewRequestWithContext(ctx, "POST", path, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := api.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp.Body)
+	return errorFromResponse(resp)
+}
+
+// unixTime returns the time of the Unix epoch seconds, or the zero
+// time if secs is zero.
+func unixTime(secs int64) time.Time {
+	if secs <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(secs, 0)
+}
@@ -0,0 +1,193 @@
+package pushover
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secrets in log output.
+const redacted = "[REDACTED]"
+
+// secretParams are the names of parameters that contain secrets, i.e.
+// App Tokens, user keys, and the credentials and secrets of the
+// Open Client API.
+var secretParams = map[string]bool{
+	"token":    true,
+	"user":     true,
+	"secret":   true,
+	"password": true,
+	"twofa":    true,
+}
+
+// attachmentParams are the names of parameters that contain attachments.
+var attachmentParams = map[string]bool{
+	"attachment":        true,
+	"attachment_base64": true,
+}
+
+// secretJSON matches secrets in JSON responses, e.g. the secret
+// returned by the Open Client API on login.
+var secretJSON = regexp.MustCompile(`("(?:secret|token)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// redactValues returns a copy of values with secrets and attachments
+// redacted.
+func redactValues(values url.Values) url.Values {
+	ret := make(url.Values, len(values))
+	for name, vs := range values {
+		switch {
+		case secretParams[name]:
+			ret[name] = []string{redacted}
+		case attachmentParams[name]:
+			ret[name] = []string{redactedBytes(len(strings.Join(vs, "")))}
+		default:
+			ret[name] = vs
+		}
+	}
+	return ret
+}
+
+// redactedBytes replaces binary data of n bytes in log output.
+func redactedBytes(n int) string {
+	return fmt.Sprintf("[REDACTED %d bytes]", n)
+}
+
+// redactURL returns a copy of u with secrets in the query redacted.
+func redactURL(u *url.URL) *url.URL {
+	ret := *u
+	if ret.RawQuery != "" {
+		ret.RawQuery = redactValues(ret.Query()).Encode()
+	}
+	return &ret
+}
+
+// logRequest returns a copy of req for logging, with secrets in the URL
+// and body redacted if redact is true. The body of req is read with
+// req.GetBody, so req can be logged after it was sent. If the body
+// cannot be read, it is omitted.
+func logRequest(req *http.Request, redact bool) *http.Request {
+	ret := req.Clone(req.Context())
+	if redact {
+		ret.URL = redactURL(req.URL)
+	}
+	ret.Body = http.NoBody
+	ret.ContentLength = 0
+	if req.GetBody == nil {
+		return ret
+	}
+	rc, err := req.GetBody()
+	if err != nil {
+		return ret
+	}
+	defer rc.Close()
+	body, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return ret
+	}
+	if redact {
+		body = redactBody(req.Header.Get("Content-Type"), body)
+	}
+	ret.Body = ioutil.NopCloser(bytes.NewReader(body))
+	ret.ContentLength = int64(len(body))
+	return ret
+}
+
+// redactBody returns body with secrets and attachments redacted,
+// depending on its content type.
+func redactBody(contentType string, body []byte) []byte {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return body
+	}
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []byte(redacted)
+		}
+		return []byte(redactValues(values).Encode())
+	case mediaType == "multipart/form-data":
+		ret, err := redactMultipart(body, params["boundary"])
+		if err != nil {
+			return []byte(redacted)
+		}
+		return ret
+	case mediaType == "application/json":
+		return redactJSON(body)
+	}
+	return body
+}
+
+// redactMultipart returns the multipart body with secrets redacted, and
+// files replaced by their size.
+func redactMultipart(body []byte, boundary string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	r := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		name := p.FormName()
+		switch {
+		case secretParams[name]:
+			data = []byte(redacted)
+		case attachmentParams[name] || p.FileName() != "":
+			data = []byte(redactedBytes(len(data)))
+		}
+		pw, err := w.CreatePart(p.Header)
+		if err != nil {
+			return nil, err
+		}
+		pw.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// redactJSON returns body with secrets in JSON redacted.
+func redactJSON(body []byte) []byte {
+	return secretJSON.ReplaceAll(body, []byte(`${1}"`+redacted+`"`))
+}
+
+// redactResponse returns a copy of resp with secrets in the body
+// redacted. The body of resp is restored, so it can still be read.
+func redactResponse(resp *http.Response) *http.Response {
+	ret := *resp
+	ret.Body = http.NoBody
+	ret.ContentLength = 0
+	ret.TransferEncoding = nil
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return &ret
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return &ret
+	}
+	body = redactBody(resp.Header.Get("Content-Type"), body)
+	ret.Body = ioutil.NopCloser(bytes.NewReader(body))
+	ret.ContentLength = int64(len(body))
+	return &ret
+}
@@ -0,0 +1,97 @@
+package pushover
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// -- SlogLogger --
+
+// SlogLogger logs requests as structured records with a slog.Logger.
+//
+// Each record has the attributes method, path, status, duration and,
+// if available, request_id, attempt, limits_remaining, the timings
+// dns, connect, tls and first_byte, and error.
+// Secrets such as the App Token and user keys are redacted by default.
+type SlogLogger struct {
+	l *slog.Logger
+	loggerOptions
+}
+
+// NewSlogLogger creates a new SlogLogger that logs to l. If l is nil,
+// slog.Default() is used. Secrets are redacted unless disabled with
+// WithRedaction.
+func NewSlogLogger(l *slog.Logger, options ...LoggerOption) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{l: l, loggerOptions: newLoggerOptions(options)}
+}
+
+// Log a roundtrip. Successful requests are logged with level Info,
+// failed requests with level Error.
+func (l *SlogLogger) Log(req *http.Request, resp *http.Response, err error, start time.Time, duration time.Duration) error {
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+	}
+	if req.URL.RawQuery != "" {
+		u := req.URL
+		if l.redact {
+			u = redactURL(u)
+		}
+		attrs = append(attrs, slog.String("query", u.RawQuery))
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			level = slog.LevelError
+		}
+	}
+	attrs = append(attrs, slog.Duration("duration", duration))
+	if id := requestIDOf(req, resp); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if n := attemptOf(req.Context()); n > 0 {
+		attrs = append(attrs, slog.Int("attempt", n))
+	}
+	if resp != nil {
+		if v := resp.Header.Get("X-Limit-App-Remaining"); v != "" {
+			if remaining, err := strconv.ParseInt(v, 10, 64); err == nil {
+				attrs = append(attrs, slog.Int64("limits_remaining", remaining))
+			}
+		}
+	}
+	if ti := TraceInfoFromContext(req.Context()); ti != nil {
+		t := ti.Timings()
+		for _, d := range []struct {
+			key string
+			d   time.Duration
+		}{{"dns", t.DNS}, {"connect", t.Connect}, {"tls", t.TLS}, {"first_byte", t.FirstByte}} {
+			if d.d > 0 {
+				attrs = append(attrs, slog.Duration(d.key, d.d))
+			}
+		}
+	}
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.l.LogAttrs(req.Context(), level, "pushover request", attrs...)
+	return nil
+}
+
+// requestIDOf returns the request ID of a request, as set e.g. by
+// RequestIDMiddleware. As middleware may send a copy of req, the
+// request of resp is preferred.
+func requestIDOf(req *http.Request, resp *http.Response) string {
+	if resp != nil && resp.Request != nil {
+		if id := resp.Request.Header.Get(DefaultRequestIDHeader); id != "" {
+			return id
+		}
+	}
+	return req.Header.Get(DefaultRequestIDHeader)
+}
@@ -0,0 +1,89 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Limit-App-Limit", "10000")
+		w.Header().Set("X-Limit-App-Remaining", "9999")
+		w.Header().Set("X-Limit-App-Reset", "1600000000")
+		w.Write([]byte(`{"status":1,"request":"1","limit":10000,"remaining":9999,"reset":1600000000}`))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	client, err := NewClient(
+		WithURL(ts.URL),
+		WithAppToken("secret-token"),
+		WithUserKey("secret-user"),
+		WithLogger(NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))),
+		WithMiddleware(RequestIDMiddleware("")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(ContextWithAttempt(context.Background(), 2), Message{Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Limits(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Fatalf("want secrets to be redacted, have %s", buf.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want, have := 2, len(lines); want != have {
+		t.Fatalf("want %d records, have %d", want, have)
+	}
+	var send struct {
+		Level           string `json:"level"`
+		Method          string `json:"method"`
+		Path            string `json:"path"`
+		Status          int    `json:"status"`
+		RequestID       string `json:"request_id"`
+		Attempt         int    `json:"attempt"`
+		LimitsRemaining int64  `json:"limits_remaining"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &send); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "INFO", send.Level; want != have {
+		t.Fatalf("want level %q, have %q", want, have)
+	}
+	if want, have := "POST /1/messages.json", send.Method+" "+send.Path; want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	if want, have := http.StatusOK, send.Status; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if send.RequestID == "" {
+		t.Fatal("want request ID")
+	}
+	if want, have := 2, send.Attempt; want != have {
+		t.Fatalf("want attempt %d, have %d", want, have)
+	}
+	if want, have := int64(9999), send.LimitsRemaining; want != have {
+		t.Fatalf("want limits remaining %d, have %d", want, have)
+	}
+
+	var limits struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &limits); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "token=%5BREDACTED%5D", limits.Query; want != have {
+		t.Fatalf("want query %q, have %q", want, have)
+	}
+}
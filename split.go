@@ -0,0 +1,369 @@
+package pushover
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SplitMessage splits m into multiple messages if the message is longer
+// than max characters. The message is broken on line boundaries if
+// possible, then on word boundaries. Each part gets the title of m with
+// a suffix like "(1/3)", and all parts share the same timestamp.
+//
+// If m is formatted as HTML, the message is never split inside of a tag,
+// and tags that span multiple parts are closed at the end of each part
+// and reopened at the start of the next. Tags are dropped if there is no
+// room for them in a part, so every part stays within max characters.
+//
+// If m fits into max characters, a slice with m as its only element
+// is returned.
+func SplitMessage(m Message, max int) []Message {
+	if max <= 0 || textLength(m.Message) <= max {
+		return []Message{m}
+	}
+	bodies := splitText(m.Message, max, m.HTML)
+	if len(bodies) <= 1 {
+		return []Message{m}
+	}
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+	parts := make([]Message, len(bodies))
+	for i, body := range bodies {
+		part := m
+		part.Message = body
+		suffix := fmt.Sprintf("(%d/%d)", i+1, len(bodies))
+		if m.Title != "" {
+			suffix = " " + suffix
+			part.Title = Truncate(m.Title, 250-textLength(suffix), ellipsis) + suffix
+		} else {
+			part.Title = suffix
+		}
+		parts[i] = part
+	}
+	return parts
+}
+
+// SendSplit sends a message, splitting it into multiple numbered parts
+// if it is too long to be sent as a single message. The parts are sent
+// in order. See SplitMessage for details.
+//
+// SendSplit returns the responses of all parts sent. If sending a part
+// fails, the responses of the parts sent so far are returned together
+// with the error.
+func (api *messagesAPI) SendSplit(ctx context.Context, m Message) ([]*SendResponse, error) {
+	if m.Markdown {
+		m.Message = MarkdownToHTML(m.Message, 0)
+		m.Markdown = false
+		m.HTML = true
+		m.Monospace = false
+	}
+	parts := SplitMessage(m, 1024)
+	responses := make([]*SendResponse, 0, len(parts))
+	for _, part := range parts {
+		resp, err := api.Send(ctx, part)
+		if err != nil {
+			return responses, err
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// atom is the smallest unit of text that splitText does not break up
+// if it can avoid it, e.g. a word including trailing whitespace or an
+// HTML tag.
+type atom struct {
+	text    string
+	tag     bool // true if text is an HTML tag
+	lineEnd bool // true if text ends with a newline
+}
+
+// splitText splits s into parts of at most max characters.
+func splitText(s string, max int, isHTML bool) []string {
+	var (
+		parts []string
+		part  []atom
+		open  []string // open HTML tags at the start of part
+	)
+
+	partLength := func(atoms []atom) int {
+		n := 0
+		for _, a := range open {
+			n += textLength(a)
+		}
+		for _, a := range atoms {
+			n += textLength(a.text)
+		}
+		return n
+	}
+	emit := func(atoms []atom) {
+		tags := tagStack(open, atoms)
+		hasText := false
+		for _, a := range atoms {
+			hasText = hasText || (!a.tag && strings.TrimSpace(a.text) != "")
+		}
+		if !hasText {
+			// Skip parts without any text, e.g. with just a tag
+			open = tags
+			return
+		}
+		var sb strings.Builder
+		for _, t := range open {
+			sb.WriteString(t)
+		}
+		for _, a := range atoms {
+			sb.WriteString(a.text)
+		}
+		for i := len(tags) - 1; i >= 0; i-- {
+			sb.WriteString(closingTag(tags[i]))
+		}
+		text := sb.String()
+		if !isHTML {
+			text = strings.TrimSpace(text)
+		}
+		parts = append(parts, text)
+		open = tags
+	}
+
+	atoms := splitAtoms(s, isHTML)
+	for i := 0; i < len(atoms); i++ {
+		a := atoms[i]
+		if a.tag && strings.HasPrefix(a.text, "</") && !isOpen(tagStack(open, part), a.text) {
+			// Skip closing tags of tags that were dropped
+			continue
+		}
+		next := append(part[:len(part):len(part)], a)
+		if partLength(next)+closingLength(tagStack(open, next)) <= max {
+			part = next
+			continue
+		}
+		if len(part) == 0 {
+			// The atom doesn't even fit into an empty part, so break it up
+			head, tail := breakAtom(a, max-partLength(nil)-closingLength(open), isHTML)
+			if head.text == "" {
+				switch {
+				case len(open) > 0:
+					// The reopened tags leave no room, so drop the
+					// innermost one and try again
+					open = open[:len(open)-1]
+					i--
+				case !a.tag:
+					// Nothing fits, e.g. a grapheme cluster longer than max
+					part = next
+				}
+				// A tag that doesn't fit on its own is dropped
+				continue
+			}
+			emit([]atom{head})
+			atoms[i] = tail
+			i--
+			continue
+		}
+		// Prefer to break at the last line end in the second half of the part
+		cutAt := len(part)
+		for j := len(part) - 1; j >= 0; j-- {
+			if part[j].lineEnd {
+				if partLength(part[:j+1]) >= max/2 {
+					cutAt = j + 1
+				}
+				break
+			}
+		}
+		rest := append([]atom{}, part[cutAt:]...)
+		emit(part[:cutAt])
+		part = nil
+		i -= len(rest) + 1
+	}
+	if len(part) > 0 {
+		emit(part)
+	}
+	return parts
+}
+
+// truncateHTML cuts the HTML in s to at most max characters including
+// markup and the trailing sequence, and reports whether s was cut. Tags,
+// entities and grapheme clusters are never broken up, and tags that are
+// open at the cut are closed.
+func truncateHTML(s string, max int, trailing string) (string, bool) {
+	if textLength(s) <= max {
+		return s, false
+	}
+	trailing = Truncate(trailing, max, "")
+	budget := max - textLength(trailing)
+
+	var (
+		sb   strings.Builder
+		n    int
+		open []string
+	)
+	// Tags after the last text are dropped at the cut, so there are
+	// no empty elements like "<b></b>"
+	keep, keepOpen := 0, []string(nil)
+	for _, a := range splitAtoms(s, true) {
+		if a.tag {
+			tags := tagStack(open, []atom{a})
+			l := textLength(a.text)
+			if n+l+closingLength(tags) > budget {
+				break
+			}
+			sb.WriteString(a.text)
+			n += l
+			open = tags
+			continue
+		}
+		head, tail := breakAtom(a, budget-n-closingLength(open), true)
+		if head.text != "" {
+			sb.WriteString(head.text)
+			n += textLength(head.text)
+			keep, keepOpen = sb.Len(), open
+		}
+		if tail.text != "" {
+			break
+		}
+	}
+	if keep < sb.Len() {
+		out := sb.String()[:keep]
+		sb.Reset()
+		sb.WriteString(out)
+		open = keepOpen
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString(closingTag(open[i]))
+	}
+	sb.WriteString(trailing)
+	return sb.String(), true
+}
+
+// splitAtoms breaks s into atoms.
+func splitAtoms(s string, isHTML bool) []atom {
+	var atoms []atom
+	var sb strings.Builder
+	inSpace := false
+	flush := func() {
+		if sb.Len() > 0 {
+			text := sb.String()
+			atoms = append(atoms, atom{text: text, lineEnd: strings.HasSuffix(text, "\n")})
+			sb.Reset()
+		}
+		inSpace = false
+	}
+	for i := 0; i < len(s); {
+		if isHTML && s[i] == '<' {
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				flush()
+				atoms = append(atoms, atom{text: s[i : i+end+1], tag: true})
+				i += end + 1
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if unicode.IsSpace(r) {
+			inSpace = true
+		} else if inSpace {
+			flush()
+		}
+		sb.WriteRune(r)
+		if r == '\n' {
+			flush()
+		}
+		i += size
+	}
+	flush()
+	return atoms
+}
+
+// breakAtom breaks a into a head of at most max characters and the tail.
+// Grapheme clusters and, in HTML mode, entities like &amp; are never
+// broken up.
+func breakAtom(a atom, max int, isHTML bool) (atom, atom) {
+	if a.tag || max <= 0 {
+		return atom{}, a
+	}
+	n, i := 0, 0
+	for i < len(a.text) {
+		size := 0
+		if isHTML && a.text[i] == '&' {
+			if end := strings.IndexByte(a.text[i:], ';'); end > 0 && end < 10 {
+				size = end + 1
+			}
+		}
+		if size == 0 {
+			size = graphemeLen(a.text[i:])
+		}
+		l := textLength(a.text[i : i+size])
+		if n+l > max {
+			break
+		}
+		n += l
+		i += size
+	}
+	return atom{text: a.text[:i]}, atom{text: a.text[i:], lineEnd: a.lineEnd}
+}
+
+// tagStack returns the HTML tags still open after the given tags
+// were open and atoms were written.
+func tagStack(open []string, atoms []atom) []string {
+	stack := append([]string{}, open...)
+	for _, a := range atoms {
+		if !a.tag {
+			continue
+		}
+		name := tagName(a.text)
+		if strings.HasPrefix(a.text, "</") {
+			for j := len(stack) - 1; j >= 0; j-- {
+				if tagName(stack[j]) == name {
+					stack = append(stack[:j], stack[j+1:]...)
+					break
+				}
+			}
+		} else if !strings.HasSuffix(a.text, "/>") {
+			stack = append(stack, a.text)
+		}
+	}
+	return stack
+}
+
+// tagName returns the lowercase name of the HTML tag, e.g. "font"
+// for `<font color="red">`.
+func tagName(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimPrefix(tag, "<"), "/")
+	if i := strings.IndexAny(tag, " \t\n/>"); i >= 0 {
+		tag = tag[:i]
+	}
+	return strings.ToLower(tag)
+}
+
+// isOpen returns true if the closing HTML tag closes one of tags.
+func isOpen(tags []string, closing string) bool {
+	name := tagName(closing)
+	for _, t := range tags {
+		if tagName(t) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// closingTag returns the closing tag of the opening HTML tag.
+func closingTag(tag string) string {
+	return "</" + tagName(tag) + ">"
+}
+
+// closingLength returns the number of characters to close all tags.
+func closingLength(tags []string) int {
+	n := 0
+	for _, t := range tags {
+		n += textLength(closingTag(t))
+	}
+	return n
+}
+
+// textLength returns the number of characters in s.
+func textLength(s string) int {
+	return utf8.RuneCountInString(s)
+}
@@ -0,0 +1,155 @@
+package pushover
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSplitMessageShort(t *testing.T) {
+	m := Message{Title: "Build", Message: "All good"}
+	parts := SplitMessage(m, 1024)
+	if want, have := 1, len(parts); want != have {
+		t.Fatalf("want %d parts, have %d", want, have)
+	}
+	if want, have := m.Title, parts[0].Title; want != have {
+		t.Fatalf("want Title=%q, have %q", want, have)
+	}
+}
+
+func TestSplitMessageText(t *testing.T) {
+	m := Message{
+		Title:   "Log",
+		Message: "first line\nsecond line\nthird line which is longer",
+	}
+	parts := SplitMessage(m, 25)
+	want := []struct {
+		Title   string
+		Message string
+	}{
+		{"Log (1/3)", "first line\nsecond line"},
+		{"Log (2/3)", "third line which is"},
+		{"Log (3/3)", "longer"},
+	}
+	if len(want) != len(parts) {
+		t.Fatalf("want %d parts, have %d: %#v", len(want), len(parts), parts)
+	}
+	for i, part := range parts {
+		if want, have := want[i].Title, part.Title; want != have {
+			t.Errorf("part %d: want Title=%q, have %q", i, want, have)
+		}
+		if want, have := want[i].Message, part.Message; want != have {
+			t.Errorf("part %d: want Message=%q, have %q", i, want, have)
+		}
+		if part.Timestamp.IsZero() || !part.Timestamp.Equal(parts[0].Timestamp) {
+			t.Errorf("part %d: want Timestamp=%v, have %v", i, parts[0].Timestamp, part.Timestamp)
+		}
+	}
+}
+
+func TestSplitMessageLongWord(t *testing.T) {
+	m := Message{Message: strings.Repeat("x", 25)}
+	parts := SplitMessage(m, 10)
+	if want, have := 3, len(parts); want != have {
+		t.Fatalf("want %d parts, have %d", want, have)
+	}
+	if want, have := "(1/3)", parts[0].Title; want != have {
+		t.Fatalf("want Title=%q, have %q", want, have)
+	}
+	if want, have := "xxxxx", parts[2].Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+}
+
+func TestSplitMessageHTML(t *testing.T) {
+	m := Message{
+		HTML:    true,
+		Message: `Status <b>very important &amp; bold text</b> <a href="https://example.com">link</a>`,
+	}
+	parts := SplitMessage(m, 50)
+	if len(parts) < 2 {
+		t.Fatalf("want multiple parts, have %d", len(parts))
+	}
+	var stripped strings.Builder
+	for i, part := range parts {
+		if n := utf8.RuneCountInString(part.Message); n > 50 {
+			t.Errorf("part %d: want at most 50 characters, have %d: %q", i, n, part.Message)
+		}
+		if want, have := strings.Count(part.Message, "<b>"), strings.Count(part.Message, "</b>"); want != have {
+			t.Errorf("part %d: want balanced <b> tags in %q", i, part.Message)
+		}
+		if strings.Count(part.Message, "<") != strings.Count(part.Message, ">") {
+			t.Errorf("part %d: tag split in %q", i, part.Message)
+		}
+		stripped.WriteString(part.Message)
+	}
+	if !strings.Contains(stripped.String(), `<a href="https://example.com">link</a>`) {
+		t.Fatalf("want link to be kept intact, have %q", stripped.String())
+	}
+	if !strings.Contains(stripped.String(), "&amp;") {
+		t.Fatalf("want entity to be kept intact, have %q", stripped.String())
+	}
+}
+
+func TestSplitMessageDeepHTML(t *testing.T) {
+	m := Message{
+		HTML:    true,
+		Message: `<b><i><u><font color="#ff0000">deeply nested and rather long text</font></u></i></b> <a href="https://example.com/a/very/long/path">link</a>`,
+	}
+	for max := 1; max <= 40; max++ {
+		var text strings.Builder
+		for i, part := range SplitMessage(m, max) {
+			if n := utf8.RuneCountInString(part.Message); n > max {
+				t.Fatalf("max=%d, part %d: want at most %d characters, have %d: %q", max, i, max, n, part.Message)
+			}
+			for _, name := range []string{"b", "i", "u", "font", "a"} {
+				if strings.Count(part.Message, "<"+name+">")+strings.Count(part.Message, "<"+name+" ") != strings.Count(part.Message, "</"+name+">") {
+					t.Fatalf("max=%d, part %d: want balanced <%s> tags in %q", max, i, name, part.Message)
+				}
+			}
+			for _, a := range splitAtoms(part.Message, true) {
+				if !a.tag {
+					text.WriteString(a.text)
+				}
+			}
+		}
+		if want, have := "deeplynestedandratherlongtextlink", strings.Join(strings.Fields(text.String()), ""); want != have {
+			t.Fatalf("max=%d: want text %q, have %q", max, want, have)
+		}
+	}
+}
+
+func TestMessagesSendSplit(t *testing.T) {
+	var forms []url.Values
+	ts := newTestServer(t, func(v url.Values) { forms = append(forms, v) })
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Message{
+		Title:     "Log",
+		Message:   strings.Repeat("line of a long log excerpt\n", 100),
+		Timestamp: time.Unix(1600000000, 0),
+	}
+	responses, err := client.Messages.SendSplit(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, len(responses); want != have {
+		t.Fatalf("want %d responses, have %d", want, have)
+	}
+	if want, have := 3, len(forms); want != have {
+		t.Fatalf("want %d requests, have %d", want, have)
+	}
+	for i, form := range forms {
+		if want, have := "Log ("+string(rune('1'+i))+"/3)", form.Get("title"); want != have {
+			t.Errorf("request %d: want title=%q, have %q", i, want, have)
+		}
+		if want, have := "1600000000", form.Get("timestamp"); want != have {
+			t.Errorf("request %d: want timestamp=%q, have %q", i, want, have)
+		}
+	}
+}
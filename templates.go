@@ -0,0 +1,182 @@
+package pushover
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	// TemplateExt is the file extension of templates loaded from a directory.
+	TemplateExt = ".tmpl"
+)
+
+// Templates is a set of named message templates.
+//
+// A template renders the fields of a Message from arbitrary data
+// with the text/template package. Each field is rendered by a
+// template definition of the same name:
+//
+//	{{define "title"}}Deploy of {{.Service}} failed{{end}}
+//	{{define "message"}}Version {{.Version}} failed on {{.Host}}.{{end}}
+//	{{define "url"}}{{.URL}}{{end}}
+//	{{define "url_title"}}Build log{{end}}
+//	{{define "priority"}}high{{end}}
+//	{{define "sound"}}siren{{end}}
+//
+// All definitions are optional. If "message" is missing, the template
+// text outside of any definition is used as the message instead.
+//
+// If the Message is formatted as HTML, the message is rendered with the
+// html/template package, so data is escaped properly.
+type Templates struct {
+	templates map[string]*messageTemplate
+}
+
+// messageTemplate is a template parsed for both text and HTML messages.
+type messageTemplate struct {
+	text *template.Template
+	html *htmltemplate.Template
+}
+
+// NewTemplates creates an empty set of templates.
+func NewTemplates() *Templates {
+	return &Templates{templates: make(map[string]*messageTemplate)}
+}
+
+// LoadTemplates loads all templates with the TemplateExt extension
+// from dir. The name of each template is its file name without
+// the extension.
+func LoadTemplates(dir string) (*Templates, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read templates: %w", err)
+	}
+	t := NewTemplates()
+	for _, fi := range files {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != TemplateExt {
+			continue
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read template: %w", err)
+		}
+		if err := t.Parse(strings.TrimSuffix(fi.Name(), TemplateExt), string(contents)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Parse adds a template with the given name, replacing any template
+// of the same name.
+func (t *Templates) Parse(name, text string) error {
+	tt, err := template.New(name).Parse(text)
+	if err != nil {
+		return fmt.Errorf("unable to parse template %q: %w", name, err)
+	}
+	ht, err := htmltemplate.New(name).Parse(text)
+	if err != nil {
+		return fmt.Errorf("unable to parse template %q: %w", name, err)
+	}
+	t.templates[name] = &messageTemplate{text: tt, html: ht}
+	return nil
+}
+
+// Names returns the names of all templates in sorted order.
+func (t *Templates) Names() []string {
+	names := make([]string, 0, len(t.templates))
+	for name := range t.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Execute renders the template with the given name with data.
+// The fields of m are used as defaults and are replaced by every
+// field the template renders to a non-empty string.
+func (t *Templates) Execute(name string, m Message, data interface{}) (Message, error) {
+	mt, ok := t.templates[name]
+	if !ok {
+		return m, fmt.Errorf("template %q not found", name)
+	}
+
+	fields := []struct {
+		name string
+		dst  *string
+	}{
+		{"title", &m.Title},
+		{"url", &m.URL},
+		{"url_title", &m.URLTitle},
+		{"sound", &m.Sound},
+	}
+	for _, f := range fields {
+		s, err := mt.executeText(f.name, data)
+		if err != nil {
+			return m, err
+		}
+		if s != "" {
+			*f.dst = s
+		}
+	}
+
+	s, err := mt.executeText("priority", data)
+	if err != nil {
+		return m, err
+	}
+	if s != "" {
+		if m.Priority, err = ParsePriority(s); err != nil {
+			return m, fmt.Errorf("template %q: %w", name, err)
+		}
+	}
+
+	if m.HTML {
+		s, err = mt.executeHTML(data)
+	} else {
+		s, err = mt.executeText("message", data)
+	}
+	if err != nil {
+		return m, err
+	}
+	if s != "" {
+		m.Message = s
+	}
+	return m, nil
+}
+
+// executeText renders the definition of the given name with the
+// text/template package. If name is "message" and the template has no
+// such definition, the template itself is rendered.
+func (mt *messageTemplate) executeText(name string, data interface{}) (string, error) {
+	t := mt.text.Lookup(name)
+	if t == nil {
+		if name != "message" {
+			return "", nil
+		}
+		t = mt.text
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("unable to execute template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// executeHTML renders the message with the html/template package.
+func (mt *messageTemplate) executeHTML(data interface{}) (string, error) {
+	t := mt.html.Lookup("message")
+	if t == nil {
+		t = mt.html
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("unable to execute template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,104 @@
+package pushover
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplatesExecute(t *testing.T) {
+	templates := NewTemplates()
+	err := templates.Parse("deploy", `
+{{define "title"}}Deploy of {{.Service}} failed{{end}}
+{{define "message"}}Version {{.Version}} failed on <b>{{.Host}}</b>{{end}}
+{{define "url"}}https://ci.example.com/{{.Build}}{{end}}
+{{define "url_title"}}Build log{{end}}
+{{define "priority"}}high{{end}}
+{{define "sound"}}siren{{end}}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]interface{}{
+		"Service": "api",
+		"Version": "1.2.3",
+		"Host":    "<prod>",
+		"Build":   42,
+	}
+
+	m, err := templates.Execute("deploy", Message{Devices: []string{"phone"}}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "Deploy of api failed", m.Title; want != have {
+		t.Fatalf("want Title=%q, have %q", want, have)
+	}
+	if want, have := "Version 1.2.3 failed on <b><prod></b>", m.Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+	if want, have := "https://ci.example.com/42", m.URL; want != have {
+		t.Fatalf("want URL=%q, have %q", want, have)
+	}
+	if want, have := "Build log", m.URLTitle; want != have {
+		t.Fatalf("want URLTitle=%q, have %q", want, have)
+	}
+	if want, have := High, m.Priority; want != have {
+		t.Fatalf("want Priority=%v, have %v", want, have)
+	}
+	if want, have := "siren", m.Sound; want != have {
+		t.Fatalf("want Sound=%q, have %q", want, have)
+	}
+	if want, have := 1, len(m.Devices); want != have {
+		t.Fatalf("want %d devices, have %d", want, have)
+	}
+
+	// HTML messages escape data
+	m, err = templates.Execute("deploy", Message{HTML: true}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "Version 1.2.3 failed on <b>&lt;prod&gt;</b>", m.Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+
+	if _, err := templates.Execute("missing", Message{}, data); err == nil {
+		t.Fatal("expected error for missing template")
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pushover-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"alert.tmpl": `{{define "title"}}{{.Name}}{{end}}Alert {{.Name}} is firing`,
+		"README.md":  "Not a template",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"alert"}, templates.Names(); len(want) != len(have) || want[0] != have[0] {
+		t.Fatalf("want Names=%v, have %v", want, have)
+	}
+	m, err := templates.Execute("alert", Message{}, map[string]string{"Name": "DiskFull"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "DiskFull", m.Title; want != have {
+		t.Fatalf("want Title=%q, have %q", want, have)
+	}
+	if want, have := "Alert DiskFull is firing", m.Message; want != have {
+		t.Fatalf("want Message=%q, have %q", want, have)
+	}
+}
@@ -0,0 +1,167 @@
+package pushover
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans around the API calls of a Client, e.g. to bridge
+// to a tracing system like OpenTelemetry without this package depending
+// on it.
+//
+// StartSpan is called with the name of the API call, e.g.
+// "pushover.messages.send", and returns the context of the span and a
+// function that ends the span with the result of the call, which is
+// nil on success.
+//
+// Use TraceInfoFromContext with the context passed to StartSpan to get
+// details of the API call, e.g. its endpoint, the priority of the
+// message, or DNS, connect and TLS timings. The details are complete
+// when the span ends.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+// WithTracer specifies the Tracer to trace API calls with.
+func WithTracer(t Tracer) ClientOption {
+	return func(c *Client) {
+		c.tracer = t
+	}
+}
+
+// -- nopTracer --
+
+// nopTracer doesn't trace anything.
+type nopTracer struct{}
+
+// StartSpan returns ctx and a function that does nothing.
+func (nopTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+
+// TraceInfo contains details of an API call.
+type TraceInfo struct {
+	// Endpoint of the API call, e.g. "messages" or "receipts/cancel".
+	Endpoint string
+	// Priority of the message, if a message is sent.
+	Priority Priority
+	// AttachmentSize is the size of the attachment in bytes, if a
+	// message with an attachment is sent.
+	AttachmentSize int64
+	// Attempt is the attempt of sending, as recorded with
+	// ContextWithAttempt, or 0.
+	Attempt int
+	// StatusCode is the HTTP status code of the response, or 0 if no
+	// response was received.
+	StatusCode int
+	// Request is the unique ID of the request as returned by Pushover.
+	Request string
+
+	mu      sync.Mutex
+	timings Timings
+}
+
+// Timings are the durations of the phases of an HTTP request.
+// Durations are zero if the phase did not happen, e.g. because
+// an existing connection was reused.
+type Timings struct {
+	// DNS is the duration of the DNS lookup.
+	DNS time.Duration
+	// Connect is the duration of establishing the TCP connection.
+	Connect time.Duration
+	// TLS is the duration of the TLS handshake.
+	TLS time.Duration
+	// FirstByte is the duration from writing the request until the
+	// first byte of the response was received.
+	FirstByte time.Duration
+	// Total is the duration of the request until the response
+	// headers were received.
+	Total time.Duration
+	// Reused is true if an existing connection was reused.
+	Reused bool
+}
+
+// Timings returns the timings of the HTTP request of the API call.
+func (ti *TraceInfo) Timings() Timings {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	return ti.timings
+}
+
+// traceInfoContextKey is the context key for the TraceInfo of an API call.
+type traceInfoContextKey struct{}
+
+// TraceInfoFromContext returns the TraceInfo of the API call of ctx,
+// or nil if there is none.
+func TraceInfoFromContext(ctx context.Context) *TraceInfo {
+	ti, _ := ctx.Value(traceInfoContextKey{}).(*TraceInfo)
+	return ti
+}
+
+// startSpan starts a span of an API call with the given details.
+func (c *Client) startSpan(ctx context.Context, name string, ti *TraceInfo) (context.Context, func(error)) {
+	ti.Attempt = attemptOf(ctx)
+	ctx = context.WithValue(ctx, traceInfoContextKey{}, ti)
+	return c.tracer.StartSpan(ctx, name)
+}
+
+// clientTrace returns a httptrace.ClientTrace that records the timings
+// of a request started at start in ti.
+func (ti *TraceInfo) clientTrace(start time.Time) *httptrace.ClientTrace {
+	var dnsStart, connectStart, tlsStart, wroteRequest time.Time
+	update := func(fn func(t *Timings)) {
+		ti.mu.Lock()
+		fn(&ti.timings)
+		ti.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			update(func(t *Timings) { t.Reused = info.Reused })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			update(func(*Timings) { dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			update(func(t *Timings) { t.DNS = time.Since(dnsStart) })
+		},
+		ConnectStart: func(network, addr string) {
+			update(func(*Timings) { connectStart = time.Now() })
+		},
+		ConnectDone: func(network, addr string, err error) {
+			update(func(t *Timings) { t.Connect = time.Since(connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			update(func(*Timings) { tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			update(func(t *Timings) { t.TLS = time.Since(tlsStart) })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			update(func(*Timings) { wroteRequest = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			update(func(t *Timings) {
+				if !wroteRequest.IsZero() {
+					t.FirstByte = time.Since(wroteRequest)
+				}
+				t.Total = time.Since(start)
+			})
+		},
+	}
+}
+
+// attachmentSize returns the size of the attachment of m, or 0.
+func attachmentSize(m Message) int64 {
+	if m.Attachment == "" {
+		return 0
+	}
+	fi, err := os.Stat(m.Attachment)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
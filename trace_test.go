@@ -0,0 +1,149 @@
+package pushover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// recordingTracer records the spans it starts.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name  string
+	info  *TraceInfo
+	ended bool
+	err   error
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	span := &recordedSpan{name: name, info: TraceInfoFromContext(ctx)}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return ctx, func(err error) {
+		span.ended = true
+		span.err = err
+	}
+}
+
+func TestTracer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/1/messages.json":
+			w.Write([]byte(`{"status":1,"request":"647d2300"}`))
+		case "/1/receipts/r1.json":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":0,"request":"1","errors":["receipt not found"]}`))
+		default:
+			w.Write([]byte(`{"status":1,"request":"1","limit":10000,"remaining":9999,"reset":1600000000}`))
+		}
+	}))
+	defer ts.Close()
+
+	attachment := filepath.Join(t.TempDir(), "avatar.png")
+	if err := ioutil.WriteFile(attachment, []byte("12345"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := &recordingTracer{}
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"), WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := ContextWithAttempt(context.Background(), 3)
+	if _, err := client.Messages.Send(ctx, Message{Message: "Hello", Priority: High, Attachment: attachment}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Limits(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Receipts.Get(context.Background(), "r1"); err == nil {
+		t.Fatal("want error, have nil")
+	}
+
+	if want, have := 3, len(tracer.spans); want != have {
+		t.Fatalf("want %d spans, have %d", want, have)
+	}
+	send := tracer.spans[0]
+	if want, have := "pushover.messages.send", send.name; want != have {
+		t.Fatalf("want span %q, have %q", want, have)
+	}
+	if !send.ended || send.err != nil {
+		t.Fatalf("want span to be ended without error, have ended=%v err=%v", send.ended, send.err)
+	}
+	if send.info == nil {
+		t.Fatal("want trace info")
+	}
+	if want, have := "messages", send.info.Endpoint; want != have {
+		t.Fatalf("want endpoint %q, have %q", want, have)
+	}
+	if want, have := High, send.info.Priority; want != have {
+		t.Fatalf("want priority %v, have %v", want, have)
+	}
+	if want, have := int64(5), send.info.AttachmentSize; want != have {
+		t.Fatalf("want attachment size %d, have %d", want, have)
+	}
+	if want, have := 3, send.info.Attempt; want != have {
+		t.Fatalf("want attempt %d, have %d", want, have)
+	}
+	if want, have := http.StatusOK, send.info.StatusCode; want != have {
+		t.Fatalf("want status code %d, have %d", want, have)
+	}
+	if want, have := "647d2300", send.info.Request; want != have {
+		t.Fatalf("want request %q, have %q", want, have)
+	}
+	if timings := send.info.Timings(); timings.Connect <= 0 || timings.FirstByte <= 0 {
+		t.Fatalf("want connect and first byte timings, have %+v", timings)
+	}
+
+	if want, have := "pushover.messages.limits", tracer.spans[1].name; want != have {
+		t.Fatalf("want span %q, have %q", want, have)
+	}
+	get := tracer.spans[2]
+	if want, have := "pushover.receipts.get", get.name; want != have {
+		t.Fatalf("want span %q, have %q", want, have)
+	}
+	if !get.ended || !IsNotFound(get.err) {
+		t.Fatalf("want span to be ended with not found, have ended=%v err=%v", get.ended, get.err)
+	}
+	if want, have := http.StatusNotFound, get.info.StatusCode; want != have {
+		t.Fatalf("want status code %d, have %d", want, have)
+	}
+}
+
+func TestJSONLoggerTimings(t *testing.T) {
+	ts := newTestServer(t, nil)
+	var buf bytes.Buffer
+	client, err := NewClient(WithURL(ts.URL), WithAppToken("token"), WithUserKey("user"), WithLogger(NewJSONLogger(&buf)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Messages.Send(context.Background(), Message{Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	var entry struct {
+		Event struct {
+			Timings struct {
+				Connect   int64 `json:"connect"`
+				FirstByte int64 `json:"first_byte"`
+			} `json:"timings"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("want valid JSON, have %v: %s", err, buf.String())
+	}
+	if entry.Event.Timings.Connect <= 0 || entry.Event.Timings.FirstByte <= 0 {
+		t.Fatalf("want timings, have %s", buf.String())
+	}
+}